@@ -0,0 +1,264 @@
+package arweave
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// SIGNATURE_TYPE_ARWEAVE is the ANS-104 signature type of the RSA-PSS signatures of Arweave wallets.
+const SIGNATURE_TYPE_ARWEAVE = 1
+
+// Sizes of the signature and of the owner, the RSA modulus, of the 4096 bits keys of Arweave wallets.
+const (
+	SIGNATURE_SIZE = 512
+	OWNER_SIZE     = 512
+)
+
+var ErrInvalidDataItem = errors.New("invalid data item")
+
+// Tag is a name and value stored with the data, e.g. its Content-Type.
+type Tag struct {
+	Name  string
+	Value string
+}
+
+// DataItem is a signed ANS-104 data item, the unit of upload of the bundlers. Bundlers post it to Arweave
+// in a bundle and the data is then served by the gateways under the id of the item.
+type DataItem struct {
+	Signature []byte
+	Owner     []byte
+	Tags      []Tag
+	Data      []byte
+}
+
+// NewDataItem signs the data and its tags with the wallet key.
+func NewDataItem(key *rsa.PrivateKey, data []byte, tags []Tag) (*DataItem, error) {
+	item := &DataItem{Owner: owner(&key.PublicKey), Tags: tags, Data: data}
+
+	digest := sha256.Sum256(item.signatureData())
+	signature, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		return nil, err
+	}
+	if len(signature) != SIGNATURE_SIZE {
+		return nil, fmt.Errorf("the wallet key must have 4096 bits, got a %d bytes signature", len(signature))
+	}
+	item.Signature = signature
+	return item, nil
+}
+
+// ID is the id of the data on Arweave, the base64url SHA-256 of the signature.
+func (d *DataItem) ID() string {
+	sum := sha256.Sum256(d.Signature)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Tag returns the value of the first tag with the name.
+func (d *DataItem) Tag(name string) string {
+	for _, t := range d.Tags {
+		if t.Name == name {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// Verify checks the signature of the item against its owner.
+func (d *DataItem) Verify() error {
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(d.Owner), E: 65537}
+	digest := sha256.Sum256(d.signatureData())
+	if err := rsa.VerifyPSS(pub, crypto.SHA256, digest[:], d.Signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDataItem, err)
+	}
+	return nil
+}
+
+// Bytes encodes the item in the binary format of ANS-104, without target nor anchor.
+func (d *DataItem) Bytes() []byte {
+	tags := encodeTags(d.Tags)
+
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.LittleEndian, uint16(SIGNATURE_TYPE_ARWEAVE))
+	b.Write(d.Signature)
+	b.Write(d.Owner)
+	b.WriteByte(0)
+	b.WriteByte(0)
+	binary.Write(b, binary.LittleEndian, uint64(len(d.Tags)))
+	binary.Write(b, binary.LittleEndian, uint64(len(tags)))
+	b.Write(tags)
+	b.Write(d.Data)
+	return b.Bytes()
+}
+
+// ParseDataItem decodes an item encoded by Bytes. Items with a target or an anchor are supported but the
+// target and the anchor are not kept.
+func ParseDataItem(b []byte) (*DataItem, error) {
+	r := bytes.NewReader(b)
+
+	var signatureType uint16
+	if err := binary.Read(r, binary.LittleEndian, &signatureType); err != nil {
+		return nil, ErrInvalidDataItem
+	}
+	if signatureType != SIGNATURE_TYPE_ARWEAVE {
+		return nil, fmt.Errorf("%w: unsupported signature type %d", ErrInvalidDataItem, signatureType)
+	}
+
+	item := &DataItem{Signature: make([]byte, SIGNATURE_SIZE), Owner: make([]byte, OWNER_SIZE)}
+	if _, err := io.ReadFull(r, item.Signature); err != nil {
+		return nil, fmt.Errorf("%w: truncated signature", ErrInvalidDataItem)
+	}
+	if _, err := io.ReadFull(r, item.Owner); err != nil {
+		return nil, fmt.Errorf("%w: truncated owner", ErrInvalidDataItem)
+	}
+
+	// the target and the anchor, 32 bytes each when present
+	for i := 0; i < 2; i++ {
+		present, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrInvalidDataItem
+		}
+		if present == 1 {
+			if _, err := io.ReadFull(r, make([]byte, 32)); err != nil {
+				return nil, fmt.Errorf("%w: truncated target or anchor", ErrInvalidDataItem)
+			}
+		}
+	}
+
+	var count, size uint64
+	if binary.Read(r, binary.LittleEndian, &count) != nil || binary.Read(r, binary.LittleEndian, &size) != nil || size > uint64(r.Len()) {
+		return nil, ErrInvalidDataItem
+	}
+	tags := make([]byte, size)
+	if _, err := io.ReadFull(r, tags); err != nil {
+		return nil, fmt.Errorf("%w: truncated tags", ErrInvalidDataItem)
+	}
+
+	var err error
+	if item.Tags, err = decodeTags(tags); err != nil {
+		return nil, err
+	}
+	if uint64(len(item.Tags)) != count {
+		return nil, fmt.Errorf("%w: %d tags, expected %d", ErrInvalidDataItem, len(item.Tags), count)
+	}
+
+	item.Data = make([]byte, r.Len())
+	if _, err := io.ReadFull(r, item.Data); err != nil {
+		return nil, ErrInvalidDataItem
+	}
+	return item, nil
+}
+
+// signatureData is the deep hash of the fields of the item, which is what the wallet signs.
+func (d *DataItem) signatureData() []byte {
+	return deepHash([][]byte{
+		[]byte("dataitem"),
+		[]byte("1"),
+		[]byte(strconv.Itoa(SIGNATURE_TYPE_ARWEAVE)),
+		d.Owner,
+		nil,
+		nil,
+		encodeTags(d.Tags),
+		d.Data,
+	})
+}
+
+// deepHash is the SHA-384 based hash of lists of byte strings of Arweave.
+func deepHash(list [][]byte) []byte {
+	acc := sha384([]byte("list" + strconv.Itoa(len(list))))
+	for _, blob := range list {
+		tag := sha384([]byte("blob" + strconv.Itoa(len(blob))))
+		acc = sha384(append(acc, sha384(append(tag, sha384(blob)...))...))
+	}
+	return acc
+}
+
+func sha384(b []byte) []byte {
+	sum := sha512.Sum384(b)
+	return sum[:]
+}
+
+// encodeTags encodes the tags as an Avro array of records of two byte strings, or nothing without tags.
+func encodeTags(tags []Tag) []byte {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var b []byte
+	b = appendLong(b, int64(len(tags)))
+	for _, t := range tags {
+		b = appendLong(b, int64(len(t.Name)))
+		b = append(b, t.Name...)
+		b = appendLong(b, int64(len(t.Value)))
+		b = append(b, t.Value...)
+	}
+	return appendLong(b, 0)
+}
+
+func decodeTags(b []byte) ([]Tag, error) {
+	var tags []Tag
+	r := bytes.NewReader(b)
+	readString := func() (string, error) {
+		n, err := binary.ReadVarint(r)
+		if err != nil || n < 0 || n > int64(r.Len()) {
+			return "", ErrInvalidDataItem
+		}
+		s := make([]byte, n)
+		if _, err := io.ReadFull(r, s); err != nil {
+			return "", ErrInvalidDataItem
+		}
+		return string(s), nil
+	}
+
+	for r.Len() > 0 {
+		count, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, ErrInvalidDataItem
+		}
+		if count == 0 {
+			break
+		}
+		if count < 0 {
+			// a negative count is followed by the size of the block
+			count = -count
+			if _, err := binary.ReadVarint(r); err != nil {
+				return nil, ErrInvalidDataItem
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			name, err := readString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readString()
+			if err != nil {
+				return nil, err
+			}
+			tags = append(tags, Tag{Name: name, Value: value})
+		}
+	}
+	return tags, nil
+}
+
+// appendLong appends the zigzag varint of Avro longs.
+func appendLong(b []byte, v int64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return append(b, buf[:binary.PutVarint(buf, v)]...)
+}
+
+func owner(key *rsa.PublicKey) []byte {
+	res := make([]byte, OWNER_SIZE)
+	n := key.N.Bytes()
+	copy(res[len(res)-len(n):], n)
+	return res
+}
@@ -0,0 +1,85 @@
+package arweave
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func newTestDataItem(t *testing.T) *DataItem {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := NewDataItem(key, []byte("<html></html>"), []Tag{{Name: "Content-Type", Value: "text/html"}, {Name: "App-Name", Value: "polymorph"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestDataItemRoundTrip(t *testing.T) {
+	item := newTestDataItem(t)
+	if err := item.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseDataItem(item.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, item) {
+		t.Errorf("ParseDataItem = %+v, want %+v", parsed, item)
+	}
+	if parsed.ID() != item.ID() {
+		t.Errorf("ID = %s, want %s", parsed.ID(), item.ID())
+	}
+	if err := parsed.Verify(); err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		name   string
+		tamper func(d *DataItem)
+	}{
+		{"data", func(d *DataItem) { d.Data = []byte("<html>!</html>") }},
+		{"tag", func(d *DataItem) { d.Tags[0].Value = "text/plain" }},
+		{"owner", func(d *DataItem) { d.Owner[10] ^= 1 }},
+		{"signature", func(d *DataItem) { d.Signature[10] ^= 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered, err := ParseDataItem(item.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.tamper(tampered)
+			if err := tampered.Verify(); !errors.Is(err, ErrInvalidDataItem) {
+				t.Errorf("Verify = %v, want %v", err, ErrInvalidDataItem)
+			}
+		})
+	}
+}
+
+func TestParseDataItemTruncated(t *testing.T) {
+	item := newTestDataItem(t)
+	b := item.Bytes()
+	// the data is the end of the item, any shorter data is valid
+	headerSize := len(b) - len(item.Data)
+
+	for n := 0; n < headerSize; n++ {
+		if _, err := ParseDataItem(b[:n]); !errors.Is(err, ErrInvalidDataItem) {
+			t.Fatalf("ParseDataItem of %d bytes = %v, want %v", n, err, ErrInvalidDataItem)
+		}
+	}
+
+	// an anchor announced but missing
+	withAnchor := append([]byte{}, b[:2+SIGNATURE_SIZE+OWNER_SIZE]...)
+	withAnchor = append(withAnchor, 0, 1, 0, 0)
+	if _, err := ParseDataItem(withAnchor); !errors.Is(err, ErrInvalidDataItem) {
+		t.Errorf("ParseDataItem with a truncated anchor = %v, want %v", err, ErrInvalidDataItem)
+	}
+}
@@ -0,0 +1,114 @@
+package arweave
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// MANIFEST_CONTENT_TYPE is the content type of the path manifests, which gateways serve as directories.
+const MANIFEST_CONTENT_TYPE = "application/x.arweave-manifest+json"
+
+// Manifest is a path manifest: the gateways serve the data of each path under "{manifest id}/{path}".
+type Manifest struct {
+	Manifest string                  `json:"manifest"`
+	Version  string                  `json:"version"`
+	Index    *ManifestIndex          `json:"index,omitempty"`
+	Paths    map[string]ManifestPath `json:"paths"`
+}
+
+type ManifestIndex struct {
+	Path string `json:"path"`
+}
+
+type ManifestPath struct {
+	ID string `json:"id"`
+}
+
+// NewManifest returns the manifest of the ids by path.
+func NewManifest(ids map[string]string) *Manifest {
+	m := &Manifest{Manifest: "arweave/paths", Version: "0.2.0", Paths: map[string]ManifestPath{}}
+	for path, id := range ids {
+		m.Paths[path] = ManifestPath{ID: id}
+	}
+	return m
+}
+
+// ReadManifest reads the raw manifest of the id from the gateway. Besides path manifests, flat JSON objects of
+// ids or URLs by path are read as manifests, which is how asset lists such as the arweaveAssetsJSON of the
+// Polymorph contract are usually published.
+func ReadManifest(ctx context.Context, client *http.Client, gatewayURL string, id string) (*Manifest, error) {
+	r, err := Fetch(ctx, client, gatewayURL, "raw/"+id)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, &UploadError{Op: "fetch", Err: err}
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("arweave manifest %s: %w", id, err)
+	}
+
+	if _, ok := raw["paths"]; ok {
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, fmt.Errorf("arweave manifest %s: %w", id, err)
+		}
+		paths := make(map[string]ManifestPath, len(m.Paths))
+		for p, entry := range m.Paths {
+			paths[CleanPath(p)] = entry
+		}
+		m.Paths = paths
+		return &m, nil
+	}
+
+	m := &Manifest{Paths: map[string]ManifestPath{}}
+	for p, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			continue
+		}
+		if ref := ParseID(s); ref != "" {
+			m.Paths[CleanPath(p)] = ManifestPath{ID: ref}
+		}
+	}
+	return m, nil
+}
+
+// CleanPath returns the path as the manifests read by ReadManifest list it, without "./", "/" nor ".." elements
+// at its start, e.g. "images/0/11.png" for "./images/0/11.png".
+func CleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// ParseID returns the id of "ar://{id}", of a gateway URL "https://arweave.net/{id}" or of a bare id,
+// or an empty string.
+func ParseID(ref string) string {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case strings.HasPrefix(ref, "ar://"):
+		ref = strings.TrimPrefix(ref, "ar://")
+	case strings.HasPrefix(ref, "http://"), strings.HasPrefix(ref, "https://"):
+		u, err := url.Parse(ref)
+		if err != nil {
+			return ""
+		}
+		ref = strings.TrimPrefix(u.Path, "/")
+		ref = strings.TrimPrefix(ref, "raw/")
+	}
+	ref = strings.SplitN(ref, "/", 2)[0]
+	if len(ref) != 43 {
+		return ""
+	}
+	return ref
+}
@@ -0,0 +1,112 @@
+package arweave
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MockGateway is an in-memory bundler and gateway, e.g. to run the publishing pipeline locally:
+// it accepts data items on POST /tx/{currency}, checking their signature, serves their data on GET /{id},
+// the raw data on GET /raw/{id} and the paths of manifests on GET /{manifest id}/{path}.
+type MockGateway struct {
+	mu    sync.Mutex
+	items map[string]*DataItem
+}
+
+func NewMockGateway() *MockGateway {
+	return &MockGateway{items: map[string]*DataItem{}}
+}
+
+// Put adds data without a signature, e.g. a manifest of layers uploaded by someone else.
+func (g *MockGateway) Put(id string, data []byte, contentType string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.items[id] = &DataItem{Data: data, Tags: []Tag{{Name: "Content-Type", Value: contentType}}}
+}
+
+// Len returns the number of uploaded items.
+func (g *MockGateway) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.items)
+}
+
+func (g *MockGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+
+	if r.Method == http.MethodPost && strings.HasPrefix(path, "tx/") {
+		g.upload(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	raw := strings.HasPrefix(path, "raw/")
+	parts := strings.SplitN(strings.TrimPrefix(path, "raw/"), "/", 2)
+
+	item := g.item(parts[0])
+	if item == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if !raw && item.Tag("Content-Type") == MANIFEST_CONTENT_TYPE {
+		var m Manifest
+		if err := json.Unmarshal(item.Data, &m); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p := ""
+		if len(parts) == 2 {
+			p = parts[1]
+		} else if m.Index != nil {
+			p = m.Index.Path
+		}
+		if item = g.item(m.Paths[p].ID); item == nil {
+			http.NotFound(w, r)
+			return
+		}
+	} else if len(parts) == 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if contentType := item.Tag("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Write(item.Data)
+}
+
+func (g *MockGateway) upload(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item, err := ParseDataItem(body)
+	if err == nil {
+		err = item.Verify()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g.mu.Lock()
+	g.items[item.ID()] = item
+	g.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundlerResponse{ID: item.ID()})
+}
+
+func (g *MockGateway) item(id string) *DataItem {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.items[id]
+}
@@ -0,0 +1,159 @@
+package arweave
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DEFAULT_BUNDLER_URL is the bundler the data items are posted to.
+const DEFAULT_BUNDLER_URL = "https://node2.irys.xyz"
+
+// DEFAULT_GATEWAY_URL serves the uploaded data under its id.
+const DEFAULT_GATEWAY_URL = "https://arweave.net"
+
+// APP_NAME is the App-Name tag of every upload.
+const APP_NAME = "Polymorph-Metadata"
+
+// UploadError is a failed call to the bundler or the gateway.
+type UploadError struct {
+	// Op is the failed operation, "upload" or "fetch"
+	Op string
+	// StatusCode is the HTTP status of the response, 0 when there was none
+	StatusCode int
+	// Message is the error returned by the bundler
+	Message string
+	Err     error
+}
+
+func (e *UploadError) Error() string {
+	msg := "arweave " + e.Op
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": HTTP %d", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// Uploader signs the uploads with a wallet and posts them to a bundler, which settles them on Arweave.
+// Uploads are paid by the wallet, bundlers usually upload small items for free.
+type Uploader struct {
+	BundlerURL string
+	GatewayURL string
+	key        *rsa.PrivateKey
+	client     *http.Client
+}
+
+type bundlerResponse struct {
+	ID string `json:"id"`
+}
+
+// NewUploader returns an uploader posting to bundlerURL, DEFAULT_BUNDLER_URL when empty, whose uploads are
+// served by gatewayURL, DEFAULT_GATEWAY_URL when empty.
+func NewUploader(key *rsa.PrivateKey, bundlerURL string, gatewayURL string) *Uploader {
+	if bundlerURL == "" {
+		bundlerURL = DEFAULT_BUNDLER_URL
+	}
+	if gatewayURL == "" {
+		gatewayURL = DEFAULT_GATEWAY_URL
+	}
+	return &Uploader{
+		BundlerURL: strings.TrimSuffix(bundlerURL, "/"),
+		GatewayURL: strings.TrimSuffix(gatewayURL, "/"),
+		key:        key,
+		client:     &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// NewUploaderFromEnv returns an uploader with the wallet file of ARWEAVE_WALLET, posting to ARWEAVE_BUNDLER_URL
+// and reading from ARWEAVE_GATEWAY_URL.
+func NewUploaderFromEnv() (*Uploader, error) {
+	path := os.Getenv("ARWEAVE_WALLET")
+	if path == "" {
+		return nil, errors.New("ARWEAVE_WALLET: the wallet file is required to upload to Arweave")
+	}
+	key, err := LoadWallet(path)
+	if err != nil {
+		return nil, fmt.Errorf("ARWEAVE_WALLET: %w", err)
+	}
+	return NewUploader(key, os.Getenv("ARWEAVE_BUNDLER_URL"), os.Getenv("ARWEAVE_GATEWAY_URL")), nil
+}
+
+// Upload signs the data with its Content-Type and the tags and returns its id.
+func (u *Uploader) Upload(ctx context.Context, data []byte, contentType string, tags []Tag) (string, error) {
+	all := append([]Tag{{Name: "Content-Type", Value: contentType}, {Name: "App-Name", Value: APP_NAME}}, tags...)
+	item, err := NewDataItem(u.key, data, all)
+	if err != nil {
+		return "", &UploadError{Op: "upload", Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.BundlerURL+"/tx/arweave", bytes.NewReader(item.Bytes()))
+	if err != nil {
+		return "", &UploadError{Op: "upload", Err: err}
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return "", &UploadError{Op: "upload", Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", responseError("upload", resp)
+	}
+
+	var res bundlerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", &UploadError{Op: "upload", StatusCode: resp.StatusCode, Err: err}
+	}
+	if res.ID != item.ID() {
+		return "", &UploadError{Op: "upload", StatusCode: resp.StatusCode, Message: fmt.Sprintf("the bundler returned id %s for item %s", res.ID, item.ID())}
+	}
+	return res.ID, nil
+}
+
+// URL returns the gateway URL of the id.
+func (u *Uploader) URL(id string) string {
+	return u.GatewayURL + "/" + id
+}
+
+// Fetch reads data from the gateway, path being an id or an id and the path of a manifest, "{id}/{path}".
+func Fetch(ctx context.Context, client *http.Client, gatewayURL string, path string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(gatewayURL, "/")+"/"+path, nil)
+	if err != nil {
+		return nil, &UploadError{Op: "fetch", Err: err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &UploadError{Op: "fetch", Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, responseError("fetch", resp)
+	}
+	return resp.Body, nil
+}
+
+func responseError(op string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &UploadError{Op: op, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
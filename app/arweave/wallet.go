@@ -0,0 +1,84 @@
+package arweave
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// jwk is the JSON Web Key of an Arweave wallet file.
+type jwk struct {
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	D   string `json:"d"`
+	P   string `json:"p"`
+	Q   string `json:"q"`
+}
+
+// LoadWallet reads the RSA key of an Arweave wallet file, a JWK as exported by the Arweave wallets.
+func LoadWallet(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseWallet(data)
+}
+
+func ParseWallet(data []byte) (*rsa.PrivateKey, error) {
+	var k jwk
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("arweave wallet: %w", err)
+	}
+	if k.Kty != "RSA" {
+		return nil, fmt.Errorf("arweave wallet: unsupported key type %q", k.Kty)
+	}
+
+	var values [5]*big.Int
+	for i, s := range []string{k.N, k.E, k.D, k.P, k.Q} {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("arweave wallet: invalid or missing RSA parameter")
+		}
+		values[i] = new(big.Int).SetBytes(b)
+	}
+
+	key := &rsa.PrivateKey{
+		PublicKey: rsa.PublicKey{N: values[0], E: int(values[1].Int64())},
+		D:         values[2],
+		Primes:    []*big.Int{values[3], values[4]},
+	}
+	if err := key.Validate(); err != nil {
+		return nil, fmt.Errorf("arweave wallet: %w", err)
+	}
+	key.Precompute()
+	return key, nil
+}
+
+// MarshalWallet encodes the key as a wallet file, e.g. for a wallet generated for the mock gateway.
+func MarshalWallet(key *rsa.PrivateKey) ([]byte, error) {
+	enc := func(n *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(n.Bytes())
+	}
+	return json.Marshal(map[string]string{
+		"kty": "RSA",
+		"n":   enc(key.N),
+		"e":   enc(big.NewInt(int64(key.E))),
+		"d":   enc(key.D),
+		"p":   enc(key.Primes[0]),
+		"q":   enc(key.Primes[1]),
+		"dp":  enc(key.Precomputed.Dp),
+		"dq":  enc(key.Precomputed.Dq),
+		"qi":  enc(key.Precomputed.Qinv),
+	})
+}
+
+// Address returns the address of the wallet, the base64url SHA-256 of its modulus.
+func Address(key *rsa.PublicKey) string {
+	sum := sha256.Sum256(key.N.Bytes())
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a bounded map that evicts the least recently used entries. It is safe for concurrent use.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int64
+	total    int64
+	weight   func(value interface{}) int64
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type entry struct {
+	key    string
+	value  interface{}
+	weight int64
+}
+
+// NewLRU returns a cache of at most size entries.
+func NewLRU(size int) *LRU {
+	if size < 1 {
+		size = 1
+	}
+	return NewWeightedLRU(int64(size), func(interface{}) int64 { return 1 })
+}
+
+// NewWeightedLRU returns a cache whose entries weigh weight(value), e.g. their size in bytes, up to capacity in total.
+// A value heavier than the capacity is not kept.
+func NewWeightedLRU(capacity int64, weight func(value interface{}) int64) *LRU {
+	return &LRU{capacity: capacity, weight: weight, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+// Get returns the value of the key and marks it as recently used.
+func (c *LRU) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// Add sets the value of the key, evicting the least recently used entry when the cache is full.
+func (c *LRU) Add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := c.weight(value)
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry)
+		c.total += w - e.weight
+		e.value, e.weight = value, w
+		c.ll.MoveToFront(el)
+	} else {
+		c.items[key] = c.ll.PushFront(&entry{key: key, value: value, weight: w})
+		c.total += w
+	}
+
+	for c.total > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Remove deletes the key.
+func (c *LRU) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	e := el.Value.(*entry)
+	c.ll.Remove(el)
+	delete(c.items, e.key)
+	c.total -= e.weight
+}
+
+// Len returns the number of entries.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const BADGES_CONFIG_VERSION = 2
+
+// Wildcard and alternatives separator of the legacy format.
+const BADGE_WILDCARD = "**"
+const BADGE_OR_SEPARATOR = "/"
+
+// Badge predicates, computed over several slots or over the history of the token.
+const (
+	// PREDICATE_BOTH_HANDS_IN holds when every hand carries an item of the set
+	PREDICATE_BOTH_HANDS_IN = "both_hands_in"
+	// PREDICATE_SAME_IN_BOTH_HANDS holds when every hand carries the same item, which is not in the set
+	PREDICATE_SAME_IN_BOTH_HANDS = "same_in_both_hands"
+	// PREDICATE_NEVER_SCRAMBLED holds when the genome of the token was never changed
+	PREDICATE_NEVER_SCRAMBLED = "never_scrambled"
+	// PREDICATE_SINGLE_TRAIT_SCRAMBLED holds when the last genome change of the token morphed a single gene
+	PREDICATE_SINGLE_TRAIT_SCRAMBLED = "single_trait_scrambled"
+)
+
+// BADGE_GROUP_PREFIX marks a group reference in a variant set, e.g. "@lightsabers".
+const BADGE_GROUP_PREFIX = "@"
+
+// BadgeConfig is the content of badges-config.json.
+type BadgeConfig struct {
+	Version int `json:"version"`
+	// Hands are the slots matched hand-agnostically, defaults to the left and the right hand
+	Hands []string `json:"hands"`
+	// Groups are named sets of variant codes that rules reference as "@name"
+	Groups map[string][]string `json:"groups"`
+	// Badges are evaluated and listed in this order
+	Badges []BadgeRule `json:"badges"`
+}
+
+// BadgeRule awards a badge when every slot requirement, the hands requirement and every predicate hold,
+// and at least one of AnyPredicates, if any.
+type BadgeRule struct {
+	Name string `json:"name"`
+	// DisplayName defaults to the name in title case
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+	// Icon defaults to BADGE_BASE_URL followed by the name and .svg
+	Icon          string                  `json:"icon,omitempty"`
+	Slots         map[string]VariantMatch `json:"slots,omitempty"`
+	Hands         *VariantMatch           `json:"hands,omitempty"`
+	Predicates    []BadgePredicate        `json:"predicates,omitempty"`
+	AnyPredicates []BadgePredicate        `json:"any_predicates,omitempty"`
+}
+
+// VariantMatch matches a variant code against sets of codes or "@group" references.
+// On Hands, AnyOf must match at least one hand and NoneOf must match no hand.
+type VariantMatch struct {
+	AnyOf  []string `json:"any_of,omitempty"`
+	NoneOf []string `json:"none_of,omitempty"`
+}
+
+// BadgePredicate is a computed requirement, see the PREDICATE_* constants.
+type BadgePredicate struct {
+	Type   string   `json:"type"`
+	Values []string `json:"values,omitempty"`
+}
+
+// Expand resolves the "@group" references of codes.
+func (b *BadgeConfig) Expand(codes []string) []string {
+	res := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if strings.HasPrefix(code, BADGE_GROUP_PREFIX) {
+			res = append(res, b.Groups[strings.TrimPrefix(code, BADGE_GROUP_PREFIX)]...)
+			continue
+		}
+		res = append(res, code)
+	}
+	return res
+}
+
+// NeedsHistory tells whether a rule depends on the genome changes of the token.
+func (b *BadgeConfig) NeedsHistory() bool {
+	for _, rule := range b.Badges {
+		if rule.NeedsHistory() {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule returns the rule of the badge with the given name or nil.
+func (b *BadgeConfig) Rule(name string) *BadgeRule {
+	for i := range b.Badges {
+		if b.Badges[i].Name == name {
+			return &b.Badges[i]
+		}
+	}
+	return nil
+}
+
+// Title returns the display name of the badge, e.g. "Ball Team Away" for "ball-team away".
+func (r BadgeRule) Title() string {
+	if r.DisplayName != "" {
+		return r.DisplayName
+	}
+	words := strings.Fields(strings.ReplaceAll(r.Name, "-", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// NeedsHistory tells whether the rule depends on the genome changes of the token.
+func (r BadgeRule) NeedsHistory() bool {
+	for _, p := range r.allPredicates() {
+		if p.Type == PREDICATE_NEVER_SCRAMBLED || p.Type == PREDICATE_SINGLE_TRAIT_SCRAMBLED {
+			return true
+		}
+	}
+	return false
+}
+
+func (r BadgeRule) allPredicates() []BadgePredicate {
+	res := make([]BadgePredicate, 0, len(r.Predicates)+len(r.AnyPredicates))
+	return append(append(res, r.Predicates...), r.AnyPredicates...)
+}
+
+// parseBadgeConfig reads badges-config.json, migrating the legacy format of positional rows on the fly.
+func parseBadgeConfig(data []byte, layout GeneLayout) (BadgeConfig, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return BadgeConfig{}, err
+	}
+
+	if _, ok := probe["badges"]; !ok {
+		return migrateLegacyBadges(data, layout)
+	}
+
+	var badges BadgeConfig
+	if err := json.Unmarshal(data, &badges); err != nil {
+		return BadgeConfig{}, err
+	}
+	if len(badges.Hands) == 0 {
+		badges.Hands = []string{"left hand", "right hand"}
+	}
+	return badges, nil
+}
+
+// migrateLegacyBadges converts rows of variant codes, one column per image layer from the bottom one,
+// where "**" matches anything and "/" separates alternatives. Both hand columns are merged into a single
+// hand-agnostic requirement. The badges that used to be computed in code are added in front.
+func migrateLegacyBadges(data []byte, layout GeneLayout) (BadgeConfig, error) {
+	rows, err := decodeOrderedRows(data)
+	if err != nil {
+		return BadgeConfig{}, err
+	}
+
+	badges := BadgeConfig{
+		Version: BADGES_CONFIG_VERSION,
+		Hands:   []string{"left hand", "right hand"},
+		Groups: map[string][]string{
+			"lightsabers": {"08", "13", "14", "15", "20", "25", "26"},
+		},
+		Badges: []BadgeRule{
+			{Name: "never-scrambled", Predicates: []BadgePredicate{{Type: PREDICATE_NEVER_SCRAMBLED}}},
+			{Name: "single-trait-scrambled", Predicates: []BadgePredicate{{Type: PREDICATE_SINGLE_TRAIT_SCRAMBLED}}},
+			{Name: "akimbo", AnyPredicates: []BadgePredicate{
+				{Type: PREDICATE_BOTH_HANDS_IN, Values: []string{BADGE_GROUP_PREFIX + "lightsabers"}},
+				{Type: PREDICATE_SAME_IN_BOTH_HANDS, Values: []string{"00"}},
+			}},
+		},
+	}
+
+	layers := layout.ByLayer()
+
+	for _, row := range rows {
+		if len(row.requirements) != len(layers) {
+			return BadgeConfig{}, fmt.Errorf("badge %q has %d columns, expected %d", row.name, len(row.requirements), len(layers))
+		}
+
+		rule := BadgeRule{Name: row.name, Slots: map[string]VariantMatch{}}
+		var hands []string
+
+		for i, requirement := range row.requirements {
+			if requirement == BADGE_WILDCARD {
+				continue
+			}
+			codes := strings.Split(requirement, BADGE_OR_SEPARATOR)
+			if contains(badges.Hands, layers[i].Name) {
+				for _, code := range codes {
+					if !contains(hands, code) {
+						hands = append(hands, code)
+					}
+				}
+				continue
+			}
+			rule.Slots[layers[i].Name] = VariantMatch{AnyOf: codes}
+		}
+
+		if len(hands) > 0 {
+			rule.Hands = &VariantMatch{AnyOf: hands}
+		}
+		badges.Badges = append(badges.Badges, rule)
+	}
+
+	return badges, nil
+}
+
+type legacyBadgeRow struct {
+	name         string
+	requirements []string
+}
+
+// decodeOrderedRows reads the legacy object keeping the order of the file, which a map would lose.
+func decodeOrderedRows(data []byte) ([]legacyBadgeRow, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var rows []legacyBadgeRow
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		row := legacyBadgeRow{name: key.(string)}
+		if err := dec.Decode(&row.requirements); err != nil {
+			return nil, fmt.Errorf("badge %q: %w", row.name, err)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
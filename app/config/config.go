@@ -1,9 +1,12 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io/fs"
 	"os"
 )
 
@@ -18,73 +21,113 @@ type ConfigService struct {
 	WeaponLeft  []string `json:"weaponleft"`
 	Type        []string `json:"type"`
 	Background  []string `json:"background"`
+	// BackgroundColors is the hex RGB color of each background variant, e.g. "1f2a44", for the background_color of the metadata
+	BackgroundColors []string `json:"background_colors"`
+	// Slots is the gene layout, defaults to DEFAULT_GENE_LAYOUT
+	Slots GeneLayout `json:"slots"`
+	// Layers tells the compositor how to draw the layers of some variants
+	Layers LayerManifest `json:"layers"`
+	// Lists holds every trait list of config.json by key, including the ones without a field above
+	Lists map[string][]string `json:"-"`
+	// Badges holds the rules of badges-config.json
+	Badges BadgeConfig `json:"-"`
+	// Version identifies the content of the loaded files
+	Version string `json:"-"`
 }
-type ConfigBadges struct {
-	Naked           []string `json:"naked"`
-	BallTeamAway    []string `json:"ball team away"`
-	BallTeamHome    []string `json:"ball team home"`
-	AmishFarmer     []string `json:"Amish farmer"`
-	Astronaut       []string `json:"Astronaut"`
-	Rainbow         []string `json:"Rainbow"`
-	Golfer          []string `json:"Golfer"`
-	Basketball      []string `json:"basketball"`
-	BeerLover       []string `json:"beer lover"`
-	Marine          []string `json:"marine"`
-	GraySuit        []string `json:"gray suit"`
-	BowTie          []string `json:"bow tie"`
-	BlackSuit       []string `json:"black suit"`
-	PlaidSuit       []string `json:"plaid suit"`
-	ClownOutfit     []string `json:"clown outfit"`
-	Stoner          []string `json:"stoner"`
-	Tennis          []string `json:"tennis"`
-	SoccerArgentina []string `json:"soccer Argentina"`
-	Soccer          []string `json:"soccer"`
-	SoccerBrazil    []string `json:"soccer Brazil"`
-	SilverKnight    []string `json:"silver knight"`
-	GoldKnight      []string `json:"gold knight"`
-	Hazmat          []string `json:"hazmat"`
-	Pimp            []string `json:"pimp"`
-	SushiChef       []string `json:"sushi chef"`
-	Hockey          []string `json:"hockey"`
-	Ninja           []string `json:"ninja"`
-	Spartan         []string `json:"spartan"`
-	Samurai         []string `json:"samurai"`
-	Tuxedo          []string `json:"tuxedo"`
-	Zombie          []string `json:"zombie"`
-	Taekwondoe      []string `json:"taekwondoe"`
+
+// NewConfigServices loads the trait config and the badges config from the filesystem.
+func NewConfigServices(configPath string, badgesPath string) (*ConfigService, error) {
+	return loadConfigServices(os.ReadFile, configPath, badgesPath)
+}
+
+// LoadConfigServices loads the trait config and the badges config from fsys, e.g. embedded files.
+func LoadConfigServices(fsys fs.FS, configPath string, badgesPath string) (*ConfigService, error) {
+	return loadConfigServices(func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) }, configPath, badgesPath)
 }
 
-func NewConfigServices(configPath string, badgesPath string) (*ConfigService, *map[string][]string) {
-	jsonFileConf, err := os.Open(configPath)
-	// if we os.Open returns an error then handle it
+func loadConfigServices(readFile func(name string) ([]byte, error), configPath string, badgesPath string) (*ConfigService, error) {
+	byteValueConf, err := readFile(configPath)
 	if err != nil {
-		fmt.Println(err)
+		return nil, fmt.Errorf("reading config: %w", err)
 	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer jsonFileConf.Close()
 
-	jsonFileBadge, err := os.Open(badgesPath)
-	// if we os.Open returns an error then handle it
+	byteValueBadge, err := readFile(badgesPath)
 	if err != nil {
-		fmt.Println(err)
+		return nil, fmt.Errorf("reading badges config: %w", err)
 	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer jsonFileBadge.Close()
 
-	byteValueConf, _ := ioutil.ReadAll(jsonFileConf)
-	byteValueBadge, _ := ioutil.ReadAll(jsonFileBadge)
+	var service ConfigService
 
-	jsonBadgeMap := map[string][]string{}
+	if err := json.Unmarshal(byteValueConf, &service); err != nil {
+		return nil, jsonError(configPath, err)
+	}
 
-	json.Unmarshal(byteValueBadge, &jsonBadgeMap)
+	if len(service.Slots) == 0 {
+		service.Slots = DEFAULT_GENE_LAYOUT
+	}
 
-	var service ConfigService
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(byteValueConf, &raw); err != nil {
+		return nil, jsonError(configPath, err)
+	}
+
+	service.Lists = map[string][]string{}
+	for key, value := range raw {
+		var list []string
+		if err := json.Unmarshal(value, &list); err == nil {
+			service.Lists[key] = list
+		}
+	}
 
-	// we initialize our Users array
+	service.Badges, err = parseBadgeConfig(byteValueBadge, service.Slots)
+	if err != nil {
+		return nil, jsonError(badgesPath, err)
+	}
+
+	if err := service.Validate(); err != nil {
+		return nil, fmt.Errorf("%s, %s: %w", configPath, badgesPath, err)
+	}
 
-	// we unmarshal our byteArray which contains our
-	// jsonFile's content into 'users' which we defined above
-	json.Unmarshal(byteValueConf, &service)
+	hash := sha256.New()
+	hash.Write(byteValueConf)
+	hash.Write(byteValueBadge)
+	service.Version = hex.EncodeToString(hash.Sum(nil))[:12]
 
-	return &service, &jsonBadgeMap
+	return &service, nil
+}
+
+// jsonError names the file and, when known, the field that could not be parsed.
+func jsonError(path string, err error) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return fmt.Errorf("%s: badly-formed JSON at position %d: %w", path, syntaxError.Offset, err)
+	case errors.As(err, &unmarshalTypeError):
+		return fmt.Errorf("%s: field %q must be %v, got a JSON %s: %w", path, unmarshalTypeError.Field, unmarshalTypeError.Type, unmarshalTypeError.Value, err)
+	default:
+		return fmt.Errorf("%s: %w", path, err)
+	}
+}
+
+// TraitList returns the trait names of a slot list, e.g. "weaponleft".
+func (c *ConfigService) TraitList(name string) []string {
+	return c.Lists[name]
+}
+
+// WithBadgeConfig returns a copy of the config that uses the badges of data, e.g. to compare two badge configs.
+func (c *ConfigService) WithBadgeConfig(data []byte) (*ConfigService, error) {
+	service := *c
+
+	badges, err := parseBadgeConfig(data, service.Slots)
+	if err != nil {
+		return nil, err
+	}
+	service.Badges = badges
+
+	if err := service.Validate(); err != nil {
+		return nil, err
+	}
+	return &service, nil
 }
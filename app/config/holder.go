@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Holder keeps the active ConfigService and swaps it atomically when a reload brings a new valid version.
+// Requests should call Current once and keep using the returned snapshot.
+type Holder struct {
+	load    func() (*ConfigService, error)
+	current atomic.Value
+	mu      sync.Mutex
+}
+
+// NewHolder loads the initial config with load, which is called again on every reload.
+func NewHolder(load func() (*ConfigService, error)) (*Holder, error) {
+	configService, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Holder{load: load}
+	h.current.Store(configService)
+	log.Infof("Loaded config version %s", configService.Version)
+
+	return h, nil
+}
+
+// Current returns the active config.
+func (h *Holder) Current() *ConfigService {
+	return h.current.Load().(*ConfigService)
+}
+
+// Reload loads and validates the config and makes it active. On error the active config is kept.
+func (h *Holder) Reload() (*ConfigService, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	configService, err := h.load()
+	if err != nil {
+		log.Errorf("Config reload failed, keeping version %s: %v", h.Current().Version, err)
+		return h.Current(), err
+	}
+
+	if previous := h.Current(); previous.Version != configService.Version {
+		h.current.Store(configService)
+		log.Infof("Reloaded config version %s, was %s", configService.Version, previous.Version)
+	}
+
+	return h.Current(), nil
+}
+
+// ReloadOnSignal reloads the config every time the process receives SIGHUP.
+func (h *Holder) ReloadOnSignal() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			h.Reload()
+		}
+	}()
+}
+
+// Watch reloads the config every interval, picking up edited files.
+func (h *Holder) Watch(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			h.Reload()
+		}
+	}()
+}
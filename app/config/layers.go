@@ -0,0 +1,163 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// LayerManifest tells the compositor how to draw the layers of some variants. Variants that are not listed
+// are drawn at the layer of their slot, at the origin and fully opaque.
+type LayerManifest struct {
+	LayerRules
+	// V2 holds the rules of the 3D still source. Its variants replace the shared ones, its hide rules are added to them
+	V2 *LayerRules `json:"v2,omitempty"`
+}
+
+// LayerRules are the placements and the hide rules of a layer source.
+type LayerRules struct {
+	// Variants holds the placements by slot name and variant code, e.g. "left hand" then "07"
+	Variants map[string]map[string]LayerPlacement `json:"variants,omitempty"`
+	Hide     []HideRule                           `json:"hide,omitempty"`
+}
+
+// LayerPlacement overrides how the layer of a variant is drawn.
+type LayerPlacement struct {
+	// Z replaces the layer of the slot in the drawing order, layers of the same Z keep the order of their slots
+	Z *int `json:"z,omitempty"`
+	// X and Y offset the layer, in pixels of the full size image
+	X int `json:"x,omitempty"`
+	Y int `json:"y,omitempty"`
+	// Opacity goes from 0 to 1, defaults to 1
+	Opacity *float64 `json:"opacity,omitempty"`
+}
+
+// HideRule leaves out the layers of Slots when every slot of When matches, e.g. the hair of a character under some headwear.
+type HideRule struct {
+	Slots []string                `json:"slots"`
+	When  map[string]VariantMatch `json:"when"`
+}
+
+// Matches tells whether code is one of AnyOf, when set, and none of NoneOf.
+func (m VariantMatch) Matches(code string) bool {
+	if len(m.AnyOf) > 0 && !contains(m.AnyOf, code) {
+		return false
+	}
+	return !contains(m.NoneOf, code)
+}
+
+// Rules returns the rules of the 2D source, or of the 3D still source when v2 is set.
+func (m *LayerManifest) Rules(v2 bool) LayerRules {
+	if !v2 || m.V2 == nil {
+		return m.LayerRules
+	}
+
+	res := LayerRules{Variants: map[string]map[string]LayerPlacement{}}
+	for _, rules := range []LayerRules{m.LayerRules, *m.V2} {
+		for slot, variants := range rules.Variants {
+			if res.Variants[slot] == nil {
+				res.Variants[slot] = map[string]LayerPlacement{}
+			}
+			for code, placement := range variants {
+				res.Variants[slot][code] = placement
+			}
+		}
+		res.Hide = append(res.Hide, rules.Hide...)
+	}
+	return res
+}
+
+// Placement returns the placement of the variant, the default one when it isn't listed.
+func (r LayerRules) Placement(slot GeneSlot, code string) LayerPlacement {
+	placement := r.Variants[slot.Name][code]
+	if placement.Z == nil {
+		z := slot.Layer
+		placement.Z = &z
+	}
+	if placement.Opacity == nil {
+		opacity := 1.0
+		placement.Opacity = &opacity
+	}
+	return placement
+}
+
+// Hidden returns the slots left out for the variant codes of the slots, by slot name.
+func (r LayerRules) Hidden(codes map[string]string) map[string]bool {
+	res := map[string]bool{}
+	for _, rule := range r.Hide {
+		if rule.matches(codes) {
+			for _, slot := range rule.Slots {
+				res[slot] = true
+			}
+		}
+	}
+	return res
+}
+
+func (h HideRule) matches(codes map[string]string) bool {
+	for slot, match := range h.When {
+		if !match.Matches(codes[slot]) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLayers checks that the manifest only references existing slots and variants.
+func (l GeneLayout) validateLayers(m LayerManifest) []string {
+	problems := l.validateLayerRules("layers", m.LayerRules)
+	if m.V2 != nil {
+		problems = append(problems, l.validateLayerRules("layers.v2", *m.V2)...)
+	}
+	return problems
+}
+
+func (l GeneLayout) validateLayerRules(name string, r LayerRules) []string {
+	var problems []string
+
+	slotNames := make([]string, 0, len(r.Variants))
+	for slot := range r.Variants {
+		slotNames = append(slotNames, slot)
+	}
+	sort.Strings(slotNames)
+
+	for _, slotName := range slotNames {
+		slot := l.Slot(slotName)
+		if slot == nil {
+			problems = append(problems, fmt.Sprintf("%s: %q is not a slot of the gene layout", name, slotName))
+			continue
+		}
+		for code, placement := range r.Variants[slotName] {
+			if !slot.hasVariant(code) {
+				problems = append(problems, fmt.Sprintf("%s: %q is not a variant of slot %q", name, code, slotName))
+			}
+			if placement.Opacity != nil && (*placement.Opacity < 0 || *placement.Opacity > 1) {
+				problems = append(problems, fmt.Sprintf("%s: the opacity of %s %q must be between 0 and 1", name, slotName, code))
+			}
+		}
+	}
+
+	for i, rule := range r.Hide {
+		if len(rule.Slots) == 0 || len(rule.When) == 0 {
+			problems = append(problems, fmt.Sprintf("%s: hide rule %d needs slots and when", name, i))
+		}
+		for _, slotName := range rule.Slots {
+			if l.Slot(slotName) == nil {
+				problems = append(problems, fmt.Sprintf("%s: hide rule %d: %q is not a slot of the gene layout", name, i, slotName))
+			}
+		}
+		for slotName, match := range rule.When {
+			slot := l.Slot(slotName)
+			if slot == nil {
+				problems = append(problems, fmt.Sprintf("%s: hide rule %d: %q is not a slot of the gene layout", name, i, slotName))
+				continue
+			}
+			for _, code := range append(append([]string{}, match.AnyOf...), match.NoneOf...) {
+				if !slot.hasVariant(code) {
+					problems = append(problems, fmt.Sprintf("%s: hide rule %d: %q is not a variant of slot %q", name, i, code, slotName))
+				}
+			}
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,74 @@
+package config
+
+import "sort"
+
+// GeneSlot describes where a trait lives in the genome and how it is presented.
+type GeneSlot struct {
+	// Name identifies the slot, e.g. "left hand"
+	Name string `json:"name"`
+	// Offset is the number of digits between the slot and the right end of the genome
+	Offset int `json:"offset"`
+	// Width is the number of digits of the slot
+	Width int `json:"width"`
+	// Count is the number of variants of the slot
+	Count int `json:"count"`
+	// TraitType is the label of the slot in the metadata attributes
+	TraitType string `json:"trait_type"`
+	// List is the key of the trait names of the slot in config.json
+	List string `json:"list"`
+	// Layer is the position of the slot in the image, 0 being the bottom layer
+	Layer int `json:"layer"`
+}
+
+// GeneLayout is the slot table of a collection. The order of the slots is the order of the metadata attributes.
+type GeneLayout []GeneSlot
+
+// DEFAULT_GENE_LAYOUT is the layout of the polymorphs collection, used when config.json doesn't define slots.
+var DEFAULT_GENE_LAYOUT = GeneLayout{
+	{Name: "character", Offset: 0, Width: 2, Count: 11, TraitType: "Character", List: "character", Layer: 1},
+	{Name: "footwear", Offset: 8, Width: 2, Count: 25, TraitType: "Footwear", List: "footwear", Layer: 2},
+	{Name: "pants", Offset: 4, Width: 2, Count: 33, TraitType: "Pants", List: "pants", Layer: 3},
+	{Name: "torso", Offset: 6, Width: 2, Count: 34, TraitType: "Torso", List: "torso", Layer: 4},
+	{Name: "eyewear", Offset: 10, Width: 2, Count: 13, TraitType: "Eyewear", List: "eyewear", Layer: 5},
+	{Name: "headwear", Offset: 12, Width: 2, Count: 31, TraitType: "Headwear", List: "headwear", Layer: 6},
+	{Name: "left hand", Offset: 16, Width: 2, Count: 32, TraitType: "Left Hand", List: "weaponleft", Layer: 7},
+	{Name: "right hand", Offset: 14, Width: 2, Count: 32, TraitType: "Right Hand", List: "weaponright", Layer: 8},
+	{Name: "background", Offset: 2, Width: 2, Count: 12, TraitType: "Background", List: "background", Layer: 0},
+}
+
+// GenomeLength returns the minimum number of digits a genome needs to carry every slot.
+func (l GeneLayout) GenomeLength() int {
+	length := 0
+	for _, s := range l {
+		if s.Offset+s.Width > length {
+			length = s.Offset + s.Width
+		}
+	}
+	return length
+}
+
+// Slot returns the slot with the given name or nil.
+func (l GeneLayout) Slot(name string) *GeneSlot {
+	for i := range l {
+		if l[i].Name == name {
+			return &l[i]
+		}
+	}
+	return nil
+}
+
+// ByLayer returns the slots ordered from the bottom image layer to the top one.
+func (l GeneLayout) ByLayer() GeneLayout {
+	res := make(GeneLayout, len(l))
+	copy(res, l)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Layer < res[j].Layer })
+	return res
+}
+
+// ByOffset returns the slots ordered from the right end of the genome to the left one.
+func (l GeneLayout) ByOffset() GeneLayout {
+	res := make(GeneLayout, len(l))
+	copy(res, l)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Offset < res[j].Offset })
+	return res
+}
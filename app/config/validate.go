@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ValidationError lists every inconsistency found between the gene layout, the trait lists and the badges.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration, %d problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks that the gene layout is sound, that every slot has a trait name for each of its variants
+// and that the badges and the layer manifest only reference existing variants. Trait lists longer than the slot are only logged,
+// as their extra entries are unreachable but harmless.
+func (c *ConfigService) Validate() error {
+	var problems []string
+
+	problems = append(problems, c.Slots.validate()...)
+
+	for _, slot := range c.Slots {
+		list, ok := c.Lists[slot.List]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("slot %q: trait list %q is missing", slot.Name, slot.List))
+		case len(list) < slot.Count:
+			problems = append(problems, fmt.Sprintf("slot %q: trait list %q has %d entries, expected %d", slot.Name, slot.List, len(list), slot.Count))
+		case len(list) > slot.Count:
+			log.Warnf("slot %q: trait list %q has %d entries, only the first %d are used", slot.Name, slot.List, len(list), slot.Count)
+		}
+	}
+
+	if len(c.Type) != len(c.Character) {
+		problems = append(problems, fmt.Sprintf("trait list \"type\" has %d entries, expected one per character (%d)", len(c.Type), len(c.Character)))
+	}
+
+	problems = append(problems, c.validateBackgroundColors()...)
+	problems = append(problems, c.Slots.validateBadges(c.Badges)...)
+	problems = append(problems, c.Slots.validateLayers(c.Layers)...)
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (l GeneLayout) validate() []string {
+	var problems []string
+
+	if len(l) == 0 {
+		return []string{"the gene layout has no slots"}
+	}
+
+	names := map[string]bool{}
+	layers := map[int]string{}
+	digits := map[int]string{}
+
+	for _, slot := range l {
+		if slot.Name == "" {
+			problems = append(problems, fmt.Sprintf("slot at offset %d has no name", slot.Offset))
+		} else if names[slot.Name] {
+			problems = append(problems, fmt.Sprintf("slot %q is declared twice", slot.Name))
+		}
+		names[slot.Name] = true
+
+		if slot.Width <= 0 || slot.Offset < 0 {
+			problems = append(problems, fmt.Sprintf("slot %q: offset %d and width %d must be positive", slot.Name, slot.Offset, slot.Width))
+			continue
+		}
+		if slot.Count <= 0 || float64(slot.Count) > pow10(slot.Width) {
+			problems = append(problems, fmt.Sprintf("slot %q: %d variants don't fit in %d digits", slot.Name, slot.Count, slot.Width))
+		}
+
+		for d := slot.Offset; d < slot.Offset+slot.Width; d++ {
+			if other, ok := digits[d]; ok {
+				problems = append(problems, fmt.Sprintf("slot %q overlaps slot %q at digit %d", slot.Name, other, d))
+				break
+			}
+			digits[d] = slot.Name
+		}
+
+		if other, ok := layers[slot.Layer]; ok {
+			problems = append(problems, fmt.Sprintf("slot %q uses image layer %d of slot %q", slot.Name, slot.Layer, other))
+		}
+		layers[slot.Layer] = slot.Name
+	}
+
+	for layer := 0; layer < len(l); layer++ {
+		if _, ok := layers[layer]; !ok {
+			problems = append(problems, fmt.Sprintf("image layer %d is not used by any slot, layers must go from 0 to %d", layer, len(l)-1))
+		}
+	}
+
+	return problems
+}
+
+// validateBadges checks that the rules only reference existing slots, groups, predicates and variants.
+func (l GeneLayout) validateBadges(badges BadgeConfig) []string {
+	var problems []string
+
+	checkCodes := func(badge string, slot GeneSlot, codes []string) {
+		for _, code := range codes {
+			if strings.HasPrefix(code, BADGE_GROUP_PREFIX) {
+				group, ok := badges.Groups[strings.TrimPrefix(code, BADGE_GROUP_PREFIX)]
+				if !ok {
+					problems = append(problems, fmt.Sprintf("badge %q: group %q is not defined", badge, code))
+					continue
+				}
+				for _, c := range group {
+					if !slot.hasVariant(c) {
+						problems = append(problems, fmt.Sprintf("badge %q: %q of group %q is not a variant of slot %q", badge, c, code, slot.Name))
+					}
+				}
+				continue
+			}
+			if !slot.hasVariant(code) {
+				problems = append(problems, fmt.Sprintf("badge %q: %q is not a variant of slot %q", badge, code, slot.Name))
+			}
+		}
+	}
+
+	var hands []GeneSlot
+	for _, name := range badges.Hands {
+		slot := l.Slot(name)
+		if slot == nil {
+			problems = append(problems, fmt.Sprintf("badges: hand slot %q is not a slot of the gene layout", name))
+			continue
+		}
+		hands = append(hands, *slot)
+	}
+
+	names := map[string]bool{}
+
+	for _, rule := range badges.Badges {
+		if rule.Name == "" {
+			problems = append(problems, "badges: a badge has no name")
+		} else if names[rule.Name] {
+			problems = append(problems, fmt.Sprintf("badge %q is declared twice", rule.Name))
+		}
+		names[rule.Name] = true
+
+		slotNames := make([]string, 0, len(rule.Slots))
+		for name := range rule.Slots {
+			slotNames = append(slotNames, name)
+		}
+		sort.Strings(slotNames)
+
+		for _, name := range slotNames {
+			slot := l.Slot(name)
+			if slot == nil {
+				problems = append(problems, fmt.Sprintf("badge %q: %q is not a slot of the gene layout", rule.Name, name))
+				continue
+			}
+			checkCodes(rule.Name, *slot, rule.Slots[name].AnyOf)
+			checkCodes(rule.Name, *slot, rule.Slots[name].NoneOf)
+		}
+
+		for _, hand := range hands {
+			if rule.Hands != nil {
+				checkCodes(rule.Name, hand, rule.Hands.AnyOf)
+				checkCodes(rule.Name, hand, rule.Hands.NoneOf)
+			}
+		}
+
+		for _, p := range rule.allPredicates() {
+			switch p.Type {
+			case PREDICATE_BOTH_HANDS_IN, PREDICATE_SAME_IN_BOTH_HANDS:
+				if p.Type == PREDICATE_BOTH_HANDS_IN && len(p.Values) == 0 {
+					problems = append(problems, fmt.Sprintf("badge %q: predicate %q needs values", rule.Name, p.Type))
+				}
+				for _, hand := range hands {
+					checkCodes(rule.Name, hand, p.Values)
+				}
+			case PREDICATE_NEVER_SCRAMBLED, PREDICATE_SINGLE_TRAIT_SCRAMBLED:
+			default:
+				problems = append(problems, fmt.Sprintf("badge %q: unknown predicate %q", rule.Name, p.Type))
+			}
+		}
+	}
+
+	return problems
+}
+
+// validateBackgroundColors checks that background_colors, when given, are hex RGB colors of existing backgrounds.
+func (c *ConfigService) validateBackgroundColors() []string {
+	var problems []string
+
+	if len(c.BackgroundColors) == 0 {
+		return nil
+	}
+
+	slot := c.Slots.Slot("background")
+	if slot == nil {
+		return []string{"background_colors: the gene layout has no \"background\" slot"}
+	}
+	if len(c.BackgroundColors) > slot.Count {
+		problems = append(problems, fmt.Sprintf("background_colors has %d entries, expected at most %d", len(c.BackgroundColors), slot.Count))
+	}
+
+	for i, color := range c.BackgroundColors {
+		if _, err := hex.DecodeString(color); err != nil || len(color) != 6 {
+			problems = append(problems, fmt.Sprintf("background_colors: %q of background %d is not a hex RGB color, e.g. \"1f2a44\"", color, i))
+		}
+	}
+
+	return problems
+}
+
+// hasVariant tells whether code is the variant code of a variant of the slot, e.g. "07".
+func (s GeneSlot) hasVariant(code string) bool {
+	variant, err := strconv.Atoi(code)
+	return err == nil && len(code) == s.Width && variant >= 0 && variant < s.Count
+}
+
+func pow10(n int) float64 {
+	res := 1.0
+	for i := 0; i < n; i++ {
+		res *= 10
+	}
+	return res
+}
@@ -0,0 +1,277 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// LAYER_INDEX_KEY is the object of a layer store that lists the content hash and the revision of its layers.
+const LAYER_INDEX_KEY = "layers-index.json"
+
+// DEFAULT_LAYER_INDEX_TTL is how long a layer index is used before it's read again.
+const DEFAULT_LAYER_INDEX_TTL = time.Minute
+
+// LayerIndex lists the layers of a layer store by path, e.g. "./images/7/07.png".
+type LayerIndex struct {
+	Layers map[string]LayerVersion `json:"layers"`
+}
+
+// LayerVersion is the content hash of a layer and its revision, 0 for the art the index was created with.
+type LayerVersion struct {
+	SHA256    string    `json:"sha256"`
+	Revision  int       `json:"revision"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LayerChange is a layer whose content differs from the previous index.
+type LayerChange struct {
+	Path     string
+	Revision int
+}
+
+type loadedLayerIndex struct {
+	index    LayerIndex
+	loadedAt time.Time
+	// pinned indexes are not read again, see SetLayerIndex
+	pinned bool
+}
+
+// revision returns the revision of the layer, 0 when it's not indexed.
+func (i LayerIndex) revision(path string) int {
+	return i.Layers[path].Revision
+}
+
+// ReadLayerIndex reads the index of the layer store, an empty one when there is none.
+func ReadLayerIndex(ctx context.Context, layers storage.ObjectStore) (LayerIndex, error) {
+	index := LayerIndex{Layers: map[string]LayerVersion{}}
+	if layers == nil {
+		return index, nil
+	}
+
+	r, err := layers.Get(ctx, LAYER_INDEX_KEY)
+	if errors.Is(err, storage.ErrNotFound) {
+		return index, nil
+	} else if err != nil {
+		return index, err
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(r).Decode(&index); err != nil {
+		return index, fmt.Errorf("%s: %w", LAYER_INDEX_KEY, err)
+	}
+	if index.Layers == nil {
+		index.Layers = map[string]LayerVersion{}
+	}
+	return index, nil
+}
+
+// WriteLayerIndex saves the index to the layer store.
+func WriteLayerIndex(ctx context.Context, layers storage.ObjectStore, index LayerIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return layers.Put(ctx, LAYER_INDEX_KEY, bytes.NewReader(data), "application/json")
+}
+
+// UpdateLayerIndex hashes every layer of the gene layout found in the layer store and bumps the revision
+// of the layers whose content differs from the previous index. Layers missing from the store are left out.
+func UpdateLayerIndex(ctx context.Context, layers storage.ObjectStore, layout config.GeneLayout, previous LayerIndex) (LayerIndex, []LayerChange, error) {
+	index := LayerIndex{Layers: map[string]LayerVersion{}}
+	var changes []LayerChange
+
+	for _, slot := range layout {
+		for variant := 0; variant < slot.Count; variant++ {
+			path := layerPath(slot.Layer, Gene(variant).toPath(slot.Width))
+
+			sum, err := hashObject(ctx, layers, path)
+			if errors.Is(err, storage.ErrNotFound) {
+				continue
+			} else if err != nil {
+				return index, nil, fmt.Errorf("%s: %w", path, err)
+			}
+
+			version, ok := previous.Layers[path]
+			switch {
+			case !ok:
+				version = LayerVersion{SHA256: sum, UpdatedAt: time.Now().UTC()}
+			case version.SHA256 != sum:
+				version = LayerVersion{SHA256: sum, Revision: version.Revision + 1, UpdatedAt: time.Now().UTC()}
+				changes = append(changes, LayerChange{Path: path, Revision: version.Revision})
+			}
+			index.Layers[path] = version
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return index, changes, nil
+}
+
+func hashObject(ctx context.Context, store storage.ObjectStore, key string) (string, error) {
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func layerPath(layer int, gene string) string {
+	return fmt.Sprintf("./images/%v/%s.png", layer, gene)
+}
+
+// layerIndex returns the index of the 2D source, or of the 3D still source when v2 is set, reading it again
+// once LayerIndexTTL has passed. The last index read is kept when it can't be read.
+func (gen *Generator) layerIndex(v2 bool) LayerIndex {
+	gen.indexMu.Lock()
+	defer gen.indexMu.Unlock()
+
+	ttl := gen.LayerIndexTTL
+	if ttl <= 0 {
+		ttl = DEFAULT_LAYER_INDEX_TTL
+	}
+
+	loaded := gen.indexes[v2]
+	if loaded != nil && (loaded.pinned || time.Since(loaded.loadedAt) < ttl) {
+		return loaded.index
+	}
+
+	layers := gen.Layers2D
+	if v2 {
+		layers = gen.Layers3D
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	index, err := ReadLayerIndex(ctx, layers)
+	if err != nil {
+		log.Errorf("Reading the layer index: %v", err)
+		if loaded != nil {
+			loaded.loadedAt = time.Now()
+			return loaded.index
+		}
+	}
+
+	if gen.indexes == nil {
+		gen.indexes = map[bool]*loadedLayerIndex{}
+	}
+	gen.indexes[v2] = &loadedLayerIndex{index: index, loadedAt: time.Now()}
+	return index
+}
+
+// SetLayerIndex makes the generator use the index for the 2D source, or for the 3D still source when v2 is set,
+// instead of the one of the layer store, e.g. to list the renders of an index that is not saved yet.
+func (gen *Generator) SetLayerIndex(v2 bool, index LayerIndex) {
+	gen.indexMu.Lock()
+	defer gen.indexMu.Unlock()
+
+	if gen.indexes == nil {
+		gen.indexes = map[bool]*loadedLayerIndex{}
+	}
+	gen.indexes[v2] = &loadedLayerIndex{index: index, loadedAt: time.Now(), pinned: true}
+}
+
+// artVersion identifies the art of the image of the genes: the revisions of its layers and the placements of the
+// layer manifest that differ from the defaults. It is empty when the image uses the original art only.
+func (gen *Generator) artVersion(genes []string, v2 bool) string {
+	layout, rules := gen.layerRules(v2)
+
+	draws, err := planLayers(layout, rules, genes, false)
+	if err != nil {
+		return ""
+	}
+	index := gen.layerIndex(v2)
+
+	var parts []string
+	hidden := rules.Hidden(slotCodes(layout.ByLayer(), genes))
+	for _, slot := range layout.ByLayer() {
+		if hidden[slot.Name] {
+			parts = append(parts, "hide "+slot.Name)
+		}
+	}
+	for _, d := range draws {
+		if rev := index.revision(d.path); rev > 0 {
+			parts = append(parts, fmt.Sprintf("%s@%d", d.path, rev))
+		}
+		if d.z != d.layer || d.offset.X != 0 || d.offset.Y != 0 || d.opacity != 1 {
+			parts = append(parts, fmt.Sprintf("%s z=%d x=%d y=%d opacity=%g", d.path, d.z, d.offset.X, d.offset.Y, d.opacity))
+		}
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:4])
+}
+
+// renderKey returns the key of the images of the genes, the genes followed by their art version if any.
+func (gen *Generator) renderKey(genes []string, v2 bool) string {
+	key := strings.Join(genes, "")
+	if version := gen.artVersion(genes, v2); version != "" {
+		key += "_" + version
+	}
+	return key
+}
+
+// ImageKey returns the object of the full 2D image of the genome in the upload store, which carries its art version.
+func (gen *Generator) ImageKey(decoded DecodedGenome) string {
+	genesKey := gen.renderKey(decoded.genes(), false)
+	for _, rendition := range gen.renditions() {
+		if rendition.Name == RENDITION_FULL {
+			return rendition.Key(genesKey)
+		}
+	}
+	return genesKey + ".jpg"
+}
+
+// RenderKey returns the key of the render of both images of the genes, which changes with the art of either image.
+// It keys the render jobs and the locks of the renders.
+func (gen *Generator) RenderKey(genes []string) string {
+	return gen.iframeKey(genes)
+}
+
+// iframeKey returns the key of the iframe of the genes, which changes with the art of either image.
+func (gen *Generator) iframeKey(genes []string) string {
+	key := strings.Join(genes, "")
+	v1, v2 := gen.artVersion(genes, false), gen.artVersion(genes, true)
+	if v1 == "" && v2 == "" {
+		return key
+	}
+	sum := sha256.Sum256([]byte(v1 + "/" + v2))
+	return key + "_" + hex.EncodeToString(sum[:4])
+}
+
+// UsesLayers tells whether the 2D image of the genome, or its 3D still when v2 is set, draws one of the paths.
+func (gen *Generator) UsesLayers(decoded DecodedGenome, v2 bool, paths map[string]bool) bool {
+	layout, rules := gen.layerRules(v2)
+	draws, err := planLayers(layout, rules, decoded.genes(), false)
+	if err != nil {
+		return false
+	}
+	for _, d := range draws {
+		if paths[d.path] {
+			return true
+		}
+	}
+	return false
+}
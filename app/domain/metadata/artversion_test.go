@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/polymorph-metadata/app/arweave"
+	"github.com/polymorph-metadata/app/storage"
+)
+
+func TestLayerFromArweaveManifest(t *testing.T) {
+	gateway := arweave.NewMockGateway()
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	b := &bytes.Buffer{}
+	if err := png.Encode(b, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	layer := strings.Repeat("l", 43)
+	gateway.Put(layer, b.Bytes(), "image/png")
+	// the paths written by cmd arweave, without "./"
+	manifest := arweave.NewManifest(map[string]string{"v1/images/0/11.png": layer})
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.Put("manifest", data, arweave.MANIFEST_CONTENT_TYPE)
+
+	store, err := storage.NewArweaveStore(context.Background(), server.URL, "manifest", "v1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decodeLayer(context.Background(), store, layerPath(0, "11")); err != nil {
+		t.Errorf("decoding layer %s: %v", layerPath(0, "11"), err)
+	}
+}
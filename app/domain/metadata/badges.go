@@ -0,0 +1,185 @@
+package metadata
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/polymorph-metadata/app/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ScrambleHistory tells how the genome of a token was changed.
+type ScrambleHistory struct {
+	NeverScrambled       bool
+	SingleTraitScrambled bool
+}
+
+// EvaluateBadges returns the badges of the genome in the order of the config.
+// Predicates over the history never hold when history is nil.
+func EvaluateBadges(badgesConfig *config.BadgeConfig, decoded DecodedGenome, history *ScrambleHistory) []string {
+	variants := map[string]string{}
+	for _, slot := range decoded.Layout() {
+		variants[slot.Name] = decoded.Gene(slot.Name).toPath(slot.Width)
+	}
+
+	hands := make([]string, 0, len(badgesConfig.Hands))
+	for _, hand := range badgesConfig.Hands {
+		hands = append(hands, variants[hand])
+	}
+
+	badges := []string{}
+	for _, rule := range badgesConfig.Badges {
+		if matchBadgeRule(badgesConfig, rule, variants, hands, history) {
+			badges = append(badges, rule.Name)
+		}
+	}
+	return badges
+}
+
+func matchBadgeRule(badgesConfig *config.BadgeConfig, rule config.BadgeRule, variants map[string]string, hands []string, history *ScrambleHistory) bool {
+	for slot, match := range rule.Slots {
+		variant := variants[slot]
+		if len(match.AnyOf) > 0 && !containsVariant(badgesConfig.Expand(match.AnyOf), variant) {
+			return false
+		}
+		if containsVariant(badgesConfig.Expand(match.NoneOf), variant) {
+			return false
+		}
+	}
+
+	if rule.Hands != nil {
+		anyOf := badgesConfig.Expand(rule.Hands.AnyOf)
+		noneOf := badgesConfig.Expand(rule.Hands.NoneOf)
+
+		matched := len(anyOf) == 0
+		for _, hand := range hands {
+			if containsVariant(noneOf, hand) {
+				return false
+			}
+			matched = matched || containsVariant(anyOf, hand)
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, p := range rule.Predicates {
+		if !holdsBadgePredicate(badgesConfig, p, hands, history) {
+			return false
+		}
+	}
+
+	if len(rule.AnyPredicates) == 0 {
+		return true
+	}
+	for _, p := range rule.AnyPredicates {
+		if holdsBadgePredicate(badgesConfig, p, hands, history) {
+			return true
+		}
+	}
+	return false
+}
+
+func holdsBadgePredicate(badgesConfig *config.BadgeConfig, p config.BadgePredicate, hands []string, history *ScrambleHistory) bool {
+	values := badgesConfig.Expand(p.Values)
+
+	switch p.Type {
+	case config.PREDICATE_BOTH_HANDS_IN:
+		for _, hand := range hands {
+			if !containsVariant(values, hand) {
+				return false
+			}
+		}
+		return len(hands) > 0
+	case config.PREDICATE_SAME_IN_BOTH_HANDS:
+		for _, hand := range hands {
+			if hand != hands[0] || containsVariant(values, hand) {
+				return false
+			}
+		}
+		return len(hands) > 0
+	case config.PREDICATE_NEVER_SCRAMBLED:
+		return history != nil && history.NeverScrambled
+	case config.PREDICATE_SINGLE_TRAIT_SCRAMBLED:
+		return history != nil && history.SingleTraitScrambled
+	}
+	return false
+}
+
+func containsVariant(list []string, variant string) bool {
+	for _, v := range list {
+		if v == variant {
+			return true
+		}
+	}
+	return false
+}
+
+// BadgeStats records the badges of the tokens and counts the holders of a badge. Only the recorded tokens are
+// counted: the ones whose metadata was served, or prerendered to cover the whole collection.
+type BadgeStats interface {
+	// Record replaces the badges of the token
+	Record(tokenId string, badges []string) error
+	// Holders returns the number of recorded tokens that hold the badge
+	Holders(badge string) (int64, error)
+}
+
+// BadgeMetadata describes an awarded badge.
+type BadgeMetadata struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+	Image       string `json:"image"`
+	Holders     *int64 `json:"holders,omitempty"`
+}
+
+// describeBadges returns the metadata of the badges, keeping their order. Holders are only counted when stats is set.
+func describeBadges(badgesConfig *config.BadgeConfig, tokenId string, badges []string, stats BadgeStats) []BadgeMetadata {
+	if stats != nil {
+		if err := stats.Record(tokenId, badges); err != nil {
+			log.Errorf("Recording badges of token %s: %v", tokenId, err)
+		}
+	}
+
+	baseBadgeUrl := os.Getenv("BADGE_BASE_URL")
+
+	res := make([]BadgeMetadata, 0, len(badges))
+	for _, name := range badges {
+		rule := badgesConfig.Rule(name)
+		if rule == nil {
+			continue
+		}
+
+		b := BadgeMetadata{
+			Name:        rule.Name,
+			DisplayName: rule.Title(),
+			Description: rule.Description,
+			Category:    rule.Category,
+			Image:       rule.Icon,
+		}
+		if b.Image == "" {
+			b.Image = fmt.Sprintf("%v%s.svg", baseBadgeUrl, rule.Name)
+		}
+
+		if stats != nil {
+			holders, err := stats.Holders(name)
+			if err != nil {
+				log.Errorf("Counting holders of badge %s: %v", name, err)
+			} else {
+				b.Holders = &holders
+			}
+		}
+
+		res = append(res, b)
+	}
+	return res
+}
+
+// badgeAttribute lists a badge among the attributes, so that marketplaces show it.
+func badgeAttribute(b BadgeMetadata) StringAttribute {
+	return StringAttribute{
+		TraitType: "Badge",
+		Value:     b.DisplayName,
+	}
+}
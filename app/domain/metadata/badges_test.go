@@ -0,0 +1,158 @@
+package metadata
+
+import (
+	"encoding/json"
+	"io/fs"
+	"io/ioutil"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/assets"
+)
+
+// legacyBadges is the evaluation of the positional rows of the legacy badges-config.json, one column per image layer
+// from the bottom one, before the rule engine. Like the migration, wildcard hand columns put no requirement on the
+// hands and "/" separates alternatives in the hand columns too, which the legacy code missed.
+func legacyBadges(rows map[string][]string, layers []string, history ScrambleHistory) []string {
+	lightsabers := []string{"08", "13", "14", "15", "20", "25", "26"}
+
+	var badges []string
+	if history.NeverScrambled {
+		badges = append(badges, "never-scrambled")
+	}
+	if history.SingleTraitScrambled {
+		badges = append(badges, "single-trait-scrambled")
+	}
+
+	left, right := layers[7], layers[8]
+	if (contains(lightsabers, left) && contains(lightsabers, right)) || (left != "00" && left == right) {
+		badges = append(badges, "akimbo")
+	}
+
+	for badge, columns := range rows {
+		matches := true
+		for i := 0; i < 7 && matches; i++ {
+			matches = columns[i] == "**" || contains(strings.Split(columns[i], "/"), layers[i])
+		}
+
+		var hands []string
+		for _, column := range columns[7:9] {
+			if column != "**" {
+				hands = append(hands, strings.Split(column, "/")...)
+			}
+		}
+		if matches && (len(hands) == 0 || contains(hands, left) || contains(hands, right)) {
+			badges = append(badges, badge)
+		}
+	}
+
+	sort.Strings(badges)
+	return badges
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyGenomes returns random genomes along with, for every row, a genome that meets it, so that every badge is awarded.
+func legacyGenomes(layout config.GeneLayout, rows map[string][]string, n int) []DecodedGenome {
+	rng := rand.New(rand.NewSource(1))
+	layers := layout.ByLayer()
+
+	random := func() DecodedGenome {
+		d := DecodedGenome{layout: layout}
+		for _, slot := range layout {
+			d.SetGene(slot.Name, Gene(rng.Intn(slot.Count)))
+		}
+		return d
+	}
+
+	var res []DecodedGenome
+	for i := 0; i < n; i++ {
+		res = append(res, random())
+	}
+
+	names := make([]string, 0, len(rows))
+	for name := range rows {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, hand := range []int{7, 8} {
+			d := random()
+			for i, column := range rows[name] {
+				if column == "**" || (i >= 7 && i != hand) {
+					continue
+				}
+				alternatives := strings.Split(column, "/")
+				gene, _ := strconv.Atoi(alternatives[rng.Intn(len(alternatives))])
+				d.SetGene(layers[i].Name, Gene(gene))
+			}
+			res = append(res, d)
+		}
+	}
+	return res
+}
+
+func TestLegacyBadgesMigration(t *testing.T) {
+	legacy, err := ioutil.ReadFile("testdata/legacy-badges-config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows map[string][]string
+	if err := json.Unmarshal(legacy, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	configJSON, err := fs.ReadFile(assets.Files(), assets.CONFIG_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrated, err := config.LoadConfigServices(fstest.MapFS{
+		"config.json": {Data: configJSON},
+		"badges.json": {Data: legacy},
+	}, "config.json", "badges.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	histories := []ScrambleHistory{{NeverScrambled: true}, {SingleTraitScrambled: true}, {}}
+	awarded := map[string]int{}
+
+	for i, decoded := range legacyGenomes(migrated.Slots, rows, 5000) {
+		history := histories[i%len(histories)]
+
+		var layers []string
+		for _, slot := range migrated.Slots.ByLayer() {
+			layers = append(layers, decoded.Gene(slot.Name).toPath(slot.Width))
+		}
+		want := legacyBadges(rows, layers, history)
+
+		got := EvaluateBadges(&migrated.Badges, decoded, &history)
+		sort.Strings(got)
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			genome, _ := decoded.Genome()
+			t.Fatalf("genome %s: badges %v, the legacy rows give %v", genome, got, want)
+		}
+		for _, badge := range got {
+			awarded[badge]++
+		}
+	}
+
+	for name := range rows {
+		if awarded[name] == 0 {
+			t.Errorf("badge %q was never awarded, the test genomes don't cover it", name)
+		}
+	}
+}
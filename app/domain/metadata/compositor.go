@@ -0,0 +1,319 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"github.com/polymorph-metadata/app/cache"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/storage"
+)
+
+// DEFAULT_LAYER_CACHE_MB bounds the decoded layers kept in memory, a full size layer takes 64MB.
+const DEFAULT_LAYER_CACHE_MB = 256
+
+// DEFAULT_LAYER_CONCURRENCY is the number of layers fetched and decoded ahead of the composition.
+const DEFAULT_LAYER_CONCURRENCY = 3
+
+// canvasPool reuses the full size canvases of the compositions, see releaseCanvas.
+var canvasPool = sync.Pool{
+	New: func() interface{} {
+		return image.NewRGBA(image.Rect(0, 0, IMG_SIZE, IMG_SIZE))
+	},
+}
+
+// layerDraw is a layer of a composition.
+type layerDraw struct {
+	path string
+	// layer is the image layer of the slot, the Z of the default placement
+	layer   int
+	z       int
+	offset  image.Point
+	opacity float64
+}
+
+// NewLayerCache returns a cache of decoded layers of at most mb megabytes.
+func NewLayerCache(mb int) *cache.LRU {
+	return cache.NewWeightedLRU(int64(mb)<<20, func(value interface{}) int64 {
+		return imageSize(value.(image.Image))
+	})
+}
+
+// imageSize returns the size in bytes of the pixels of the image.
+func imageSize(img image.Image) int64 {
+	switch i := img.(type) {
+	case *image.NRGBA:
+		return int64(len(i.Pix))
+	case *image.RGBA:
+		return int64(len(i.Pix))
+	case *image.Paletted:
+		return int64(len(i.Pix))
+	}
+	b := img.Bounds()
+	return int64(b.Dx()) * int64(b.Dy()) * 4
+}
+
+// layerRules returns the gene layout and the layer rules of the 2D source, or of the 3D still source when v2 is set.
+func (gen *Generator) layerRules(v2 bool) (config.GeneLayout, config.LayerRules) {
+	if gen.Config == nil {
+		return config.DEFAULT_GENE_LAYOUT, config.LayerRules{}
+	}
+	configService := gen.Config.Current()
+	return configService.Slots, configService.Layers.Rules(v2)
+}
+
+// ComposeImage composes the 2D image of the genome, or its 3D still when v2 is set, without reading nor writing
+// the upload stores, e.g. to measure renders. The image must be released once encoded.
+func (gen *Generator) ComposeImage(ctx context.Context, decoded DecodedGenome, v2 bool, transparent bool) (image.Image, func(), error) {
+	img, err := gen.composeGenes(ctx, decoded.genes(), v2, transparent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return img, func() { releaseCanvas(img) }, nil
+}
+
+// composeGenes composes the layers of the genes, given from the top layer as by DecodedGenome.genes,
+// following the layer manifest. The bottom layer, the background, is left out when transparent.
+// The canvas comes from a pool, release it with releaseCanvas once encoded.
+func (gen *Generator) composeGenes(ctx context.Context, genes []string, v2 bool, transparent bool) (*image.RGBA, error) {
+	layout, rules := gen.layerRules(v2)
+
+	draws, err := planLayers(layout, rules, genes, transparent)
+	if err != nil {
+		return nil, err
+	}
+
+	layers, source := gen.Layers2D, "v1"
+	if v2 {
+		layers, source = gen.Layers3D, "v2"
+	}
+	return gen.combineRemoteImages(ctx, layers, source, gen.layerIndex(v2), draws)
+}
+
+// planLayers lists the layers of the genes in drawing order, from the bottom one. Every layer is drawn at the Z of
+// its placement, the layer of its slot by default, and layers of the same Z keep the order of their slots.
+func planLayers(layout config.GeneLayout, rules config.LayerRules, genes []string, transparent bool) ([]layerDraw, error) {
+	slots := layout.ByLayer()
+	if len(slots) != len(genes) {
+		return nil, fmt.Errorf("%d genes for %d image layers", len(genes), len(slots))
+	}
+
+	// Reverse
+	revGenes := reverseGenesOrder(genes)
+
+	hidden := rules.Hidden(slotCodes(slots, genes))
+
+	var draws []layerDraw
+	for i, slot := range slots {
+		if (transparent && i == 0) || hidden[slot.Name] {
+			continue
+		}
+		placement := rules.Placement(slot, revGenes[i])
+		draws = append(draws, layerDraw{
+			path:    layerPath(i, revGenes[i]),
+			layer:   slot.Layer,
+			z:       *placement.Z,
+			offset:  image.Pt(placement.X, placement.Y),
+			opacity: *placement.Opacity,
+		})
+	}
+
+	sort.SliceStable(draws, func(i, j int) bool { return draws[i].z < draws[j].z })
+
+	return draws, nil
+}
+
+// slotCodes returns the variant codes of the genes, given from the top layer, by slot name.
+func slotCodes(slotsByLayer config.GeneLayout, genes []string) map[string]string {
+	codes := map[string]string{}
+	for i, gene := range reverseGenesOrder(genes) {
+		if i < len(slotsByLayer) {
+			codes[slotsByLayer[i].Name] = gene
+		}
+	}
+	return codes
+}
+
+type decodedLayer struct {
+	img image.Image
+	err error
+}
+
+// combineRemoteImages draws the layers on a transparent canvas, in order. The layers are fetched and decoded
+// in parallel, at most LayerConcurrency ahead of the one being drawn, so that only a few of them are held in memory.
+func (gen *Generator) combineRemoteImages(ctx context.Context, layers storage.ObjectStore, source string, index LayerIndex, draws []layerDraw) (*image.RGBA, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := gen.LayerConcurrency
+	if concurrency <= 0 {
+		concurrency = DEFAULT_LAYER_CONCURRENCY
+	}
+	ahead := make(chan struct{}, concurrency)
+
+	results := make([]chan decodedLayer, len(draws))
+	for i := range results {
+		results[i] = make(chan decodedLayer, 1)
+	}
+
+	// The layers are started in drawing order, so that the one being drawn always holds a slot
+	go func() {
+		for i, d := range draws {
+			select {
+			case ahead <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(i int, path string) {
+				img, err := gen.decodeCachedLayer(ctx, layers, fmt.Sprintf("%s/%s@%d", source, path, index.revision(path)), path)
+				results[i] <- decodedLayer{img, err}
+			}(i, d.path)
+		}
+	}()
+
+	dst := newCanvas()
+
+	for i, d := range draws {
+		var layer decodedLayer
+		select {
+		case layer = <-results[i]:
+		case <-ctx.Done():
+			releaseCanvas(dst)
+			return nil, ctx.Err()
+		}
+		if layer.err != nil {
+			releaseCanvas(dst)
+			return nil, layer.err
+		}
+		drawLayer(dst, layer.img, d)
+		<-ahead
+	}
+
+	return dst, nil
+}
+
+// drawLayer blends the layer over dst at its offset and opacity.
+func drawLayer(dst *image.RGBA, layer image.Image, d layerDraw) {
+	r := layer.Bounds().Add(d.offset)
+	if d.opacity >= 1 {
+		draw.Draw(dst, r, layer, layer.Bounds().Min, draw.Over)
+		return
+	}
+	mask := image.NewUniform(color.Alpha{A: uint8(d.opacity * 255)})
+	draw.DrawMask(dst, r, layer, layer.Bounds().Min, mask, image.Point{}, draw.Over)
+}
+
+func newCanvas() *image.RGBA {
+	dst := canvasPool.Get().(*image.RGBA)
+	for i := range dst.Pix {
+		dst.Pix[i] = 0
+	}
+	return dst
+}
+
+// releaseCanvas gives the canvas back to the pool, it must not be used afterwards.
+func releaseCanvas(dst *image.RGBA) {
+	if dst != nil {
+		canvasPool.Put(dst)
+	}
+}
+
+// decodeCachedLayer returns the decoded layer from the layer cache, or fetches it. The cache key names
+// the source and the revision of the layer. Concurrent misses of a layer share one fetch, which isn't bound to the
+// context of the first caller but to RenderTimeout.
+func (gen *Generator) decodeCachedLayer(ctx context.Context, layers storage.ObjectStore, key string, path string) (image.Image, error) {
+	if gen.LayerCache == nil {
+		return decodeLayer(ctx, layers, path)
+	}
+
+	if img, ok := gen.LayerCache.Get(key); ok {
+		return img.(image.Image), nil
+	}
+
+	res := gen.flight.DoChan("layer/"+key, func() (interface{}, error) {
+		if img, ok := gen.LayerCache.Get(key); ok {
+			return img, nil
+		}
+
+		timeout := gen.RenderTimeout
+		if timeout <= 0 {
+			timeout = DEFAULT_RENDER_TIMEOUT
+		}
+		fetchCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		img, err := decodeLayer(fetchCtx, layers, path)
+		if err != nil {
+			return nil, err
+		}
+		gen.LayerCache.Add(key, img)
+		return img, nil
+	})
+
+	select {
+	case r := <-res:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		return r.Val.(image.Image), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func decodeLayer(ctx context.Context, layers storage.ObjectStore, path string) (image.Image, error) {
+	r, err := layers.Get(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer r.Close()
+
+	img, err := imaging.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image %s: %w", path, err)
+	}
+	return trimLayer(img), nil
+}
+
+// trimLayer copies the pixels of the layer that are not fully transparent, keeping their position in the bounds
+// of the copy, as most layers only cover a small part of the canvas.
+func trimLayer(img image.Image) image.Image {
+	src, ok := img.(*image.NRGBA)
+	if !ok {
+		return img
+	}
+
+	b := src.Bounds()
+	r := image.Rectangle{}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, y) : src.PixOffset(b.Min.X, y)+b.Dx()*4]
+		first, last := -1, -1
+		for x := 0; x < b.Dx(); x++ {
+			if row[x*4+3] != 0 {
+				if first < 0 {
+					first = x
+				}
+				last = x
+			}
+		}
+		if first >= 0 {
+			r = r.Union(image.Rect(b.Min.X+first, y, b.Min.X+last+1, y+1))
+		}
+	}
+
+	if r == b {
+		return src
+	}
+
+	dst := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(dst.Pix[dst.PixOffset(r.Min.X, y):], src.Pix[src.PixOffset(r.Min.X, y):src.PixOffset(r.Max.X, y)])
+	}
+	return dst
+}
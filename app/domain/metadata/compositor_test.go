@@ -0,0 +1,181 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/storage"
+)
+
+// memoryLayerStore serves PNG layers from memory and counts their downloads.
+type memoryLayerStore struct {
+	layers map[string][]byte
+	gets   int64
+	// delay holds every download, so that concurrent misses overlap
+	delay time.Duration
+}
+
+func (s *memoryLayerStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	atomic.AddInt64(&s.gets, 1)
+	time.Sleep(s.delay)
+	data, ok := s.layers[key]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryLayerStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.layers[key] = data
+	return nil
+}
+
+func (s *memoryLayerStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, ok := s.layers[key]
+	return ok, nil
+}
+
+func (s *memoryLayerStore) URL(key string) string {
+	return "memory://" + key
+}
+
+// newLayerStore returns the full size layers of the genes, each one covering a band of the canvas.
+func newLayerStore(tb testing.TB, genes []string) *memoryLayerStore {
+	tb.Helper()
+	draws, err := planLayers(config.DEFAULT_GENE_LAYOUT, config.LayerRules{}, genes, false)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	store := &memoryLayerStore{layers: map[string][]byte{}}
+	for i, d := range draws {
+		img := image.NewNRGBA(image.Rect(0, 0, IMG_SIZE, IMG_SIZE))
+		band := image.Rect(0, i*IMG_SIZE/len(draws), IMG_SIZE, (i+2)*IMG_SIZE/len(draws))
+		for y := band.Min.Y; y < band.Max.Y && y < IMG_SIZE; y++ {
+			for x := band.Min.X; x < band.Max.X; x++ {
+				img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(i * 20), A: 200})
+			}
+		}
+		b := &bytes.Buffer{}
+		if err := png.Encode(b, img); err != nil {
+			tb.Fatal(err)
+		}
+		store.layers[d.path] = b.Bytes()
+	}
+	return store
+}
+
+func testGenes(tb testing.TB) []string {
+	tb.Helper()
+	g := Genome("1070305021020301108")
+	decoded, err := g.Decode(config.DEFAULT_GENE_LAYOUT)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return decoded.genes()
+}
+
+func TestDecodeCachedLayerSharesMisses(t *testing.T) {
+	path := layerPath(0, "11")
+	b := &bytes.Buffer{}
+	if err := png.Encode(b, image.NewNRGBA(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+	store := &memoryLayerStore{layers: map[string][]byte{path: b.Bytes()}, delay: 50 * time.Millisecond}
+	gen := &Generator{Layers2D: store, LayerCache: NewLayerCache(DEFAULT_LAYER_CACHE_MB)}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := gen.decodeCachedLayer(context.Background(), store, "v1/"+path+"@0", path)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("%d downloads of the layer, want 1", store.gets)
+	}
+}
+
+func benchmarkCompose(b *testing.B, warm bool) {
+	genes := testGenes(b)
+	gen := &Generator{Layers2D: newLayerStore(b, genes)}
+	ctx := context.Background()
+
+	if warm {
+		gen.LayerCache = NewLayerCache(DEFAULT_LAYER_CACHE_MB)
+		img, err := gen.composeGenes(ctx, genes, false, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		releaseCanvas(img)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !warm {
+			gen.LayerCache = NewLayerCache(DEFAULT_LAYER_CACHE_MB)
+		}
+		img, err := gen.composeGenes(ctx, genes, false, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		releaseCanvas(img)
+	}
+}
+
+// BenchmarkComposeColdCache composes an image whose layers are all fetched and decoded.
+func BenchmarkComposeColdCache(b *testing.B) {
+	benchmarkCompose(b, false)
+}
+
+// BenchmarkComposeWarmCache composes an image whose layers are all in the layer cache.
+func BenchmarkComposeWarmCache(b *testing.B) {
+	benchmarkCompose(b, true)
+}
+
+// BenchmarkComposeParallel composes images concurrently from a cold layer cache shared by the compositions,
+// as the renders of concurrent requests do.
+func BenchmarkComposeParallel(b *testing.B) {
+	genes := testGenes(b)
+	gen := &Generator{Layers2D: newLayerStore(b, genes), LayerCache: NewLayerCache(DEFAULT_LAYER_CACHE_MB)}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			img, err := gen.composeGenes(ctx, genes, false, false)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			releaseCanvas(img)
+		}
+	})
+}
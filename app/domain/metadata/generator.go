@@ -2,205 +2,361 @@ package metadata
 
 import (
 	"bytes"
-	"cloud.google.com/go/storage"
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"github.com/disintegration/imaging"
+	"github.com/polymorph-metadata/app/arweave"
+	"github.com/polymorph-metadata/app/cache"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/ipfs"
+	"github.com/polymorph-metadata/app/storage"
+	"github.com/polymorph-metadata/assets"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 	"image"
-	"image/color"
 	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
+	"strconv"
+	"sync"
 	"text/template"
+	"time"
 )
 
-const IpfsBaseUploadURL = `https://api.pinata.cloud/pinning/pinFileToIPFS`
+const IMG_SIZE = 4000
 
-type pinataBodyResponse struct {
-	IPFSHash string `json:"IpfsHash"`
-	PinSize  string `json:"PinSize"`
+// IFRAME_FILE_NAME is the file name of the iframe HTML pinned to IPFS.
+const IFRAME_FILE_NAME = "iframe-go.html"
+
+// DEFAULT_RENDER_TIMEOUT bounds the shared render of the images of a genome, below the default lease TTL.
+const DEFAULT_RENDER_TIMEOUT = 90 * time.Second
+
+// CID_INDEX_PREFIX prefixes the objects of the iframes store that hold the CID of each iframe, cids/{iframe}.
+const CID_INDEX_PREFIX = "cids/"
+
+// Generator renders the images and the iframe of a genome into its stores.
+type Generator struct {
+	// Layers2D and Layers3D hold the layer images, images/{layer}/{gene}.png
+	Layers2D storage.ObjectStore
+	Layers3D storage.ObjectStore
+	// Images2D and Images3D receive the rendered images, their URLs are the image URLs of the metadata
+	Images2D storage.ObjectStore
+	Images3D storage.ObjectStore
+	// Iframes receives the iframe HTMLs
+	Iframes storage.ObjectStore
+	// Pinner pins the iframes to IPFS, the metadata has no animation URL when it's nil
+	Pinner IPFSPinner
+	// CIDVersion is the version of the CIDs computed by the generator, it must match the one of the pinner
+	CIDVersion int
+	// IPFSGateway prefixes the CIDs of the gateway URLs of the images, defaults to DEFAULT_IPFS_GATEWAY_URL
+	IPFSGateway string
+	// Arweave, when set, publishes to Arweave instead of IPFS
+	Arweave ArweaveUploader
+	// PublishRenditions publishes every rendition too, the metadata image is then an ipfs:// or ar:// URL and the
+	// iframe uses their gateway URLs
+	PublishRenditions bool
+	// LegacyFields keeps the legacy fields of the first clients in the metadata, see Metadata.Standard
+	LegacyFields bool
+	// Queue renders the missing images in the background, they are rendered within the request when it's nil
+	Queue *RenderQueue
+	// Placeholder2D and Placeholder3D are the image URLs of genomes whose render is queued
+	Placeholder2D string
+	Placeholder3D string
+	// Renditions are rendered for both images, defaults to DEFAULT_RENDITIONS
+	Renditions []Rendition
+	// Locker, when set, lets a single instance render the images of a genome at a time
+	Locker Locker
+	// Config provides the gene layout and the layer manifest of the renders, the default layout without manifest when nil
+	Config *config.Holder
+	// LayerCache keeps the most used decoded layers, they are decoded for every render when it's nil
+	LayerCache *cache.LRU
+	// LayerConcurrency is the number of layers fetched and decoded ahead of the composition, defaults to DEFAULT_LAYER_CONCURRENCY
+	LayerConcurrency int
+	// LayerIndexTTL is how long the layer indexes are used before being read again, defaults to DEFAULT_LAYER_INDEX_TTL
+	LayerIndexTTL time.Duration
+	// RenderTimeout bounds the render of the images of a genome, defaults to DEFAULT_RENDER_TIMEOUT. It should stay
+	// below the TTL of the leases of the Locker
+	RenderTimeout time.Duration
+
+	// flight lets a single request of the process render or pin the artifacts of a genome at a time
+	flight singleflight.Group
+
+	indexMu sync.Mutex
+	indexes map[bool]*loadedLayerIndex
+
+	// cids remembers the indexed CID of the most used iframes
+	cids *cache.LRU
 }
 
-const IMG_SIZE = 4000
+// IPFSPinner adds content to IPFS and keeps it pinned, see the ipfs package for the Pinata, Kubo and mock pinners.
+type IPFSPinner interface {
+	// Pin returns the CID of the content, uploading and pinning it unless it's already pinned
+	Pin(ctx context.Context, content []byte, meta ipfs.PinMetadata) (string, error)
+	// IsPinned tells whether the CID is pinned
+	IsPinned(ctx context.Context, cid string) (bool, error)
+}
 
-// New bucket for 3d polymorphs
+// Locker is a lock shared by every instance of the service, see storage.LeaseLocker.
+type Locker interface {
+	// Lock waits until the lock of the key is acquired and returns its release
+	Lock(ctx context.Context, key string) (func(), error)
+}
 
-func imageExists(imageURL string) bool {
-	resp, err := http.Get(imageURL)
-	if err != nil {
-		log.Fatalln(err)
+// NewGeneratorFromEnv opens the stores named by the GCLOUD_*_BUCKET_NAME and IFRAME_HTMLS_BUCKET_NAME variables.
+// A bare name is a Google Cloud Storage bucket, see storage.Open for the local and S3 locations.
+// The existence of rendered objects is cached in memory, up to EXISTENCE_CACHE_SIZE objects per store,
+// and in rendered when it's not nil. Decoded layers are cached up to LAYER_CACHE_MB megabytes, 0 disables the cache.
+// Iframes, and the renditions when PUBLISH_RENDITIONS is true, are pinned with the pinner named by IPFS_PINNER as CIDs
+// of IPFS_CID_VERSION, see ipfs.NewPinnerFromEnv, or uploaded to Arweave when PUBLISHER is "arweave",
+// see arweave.NewUploaderFromEnv.
+func NewGeneratorFromEnv(ctx context.Context, rendered storage.ExistenceCache) (*Generator, error) {
+	var gen Generator
+
+	cacheSize := storage.DEFAULT_EXISTENCE_CACHE_SIZE
+	if size, err := strconv.Atoi(os.Getenv("EXISTENCE_CACHE_SIZE")); err == nil && size > 0 {
+		cacheSize = size
 	}
-	return resp.StatusCode != 404
-}
-func objectExists(imageURL string) bool {
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
 
-	iframeHtmlsBucketName := os.Getenv("IFRAME_HTMLS_BUCKET_NAME")
+	stores := []struct {
+		store     *storage.ObjectStore
+		location  string
+		publicURL string
+		output    bool
+	}{
+		{&gen.Layers2D, "GCLOUD_SOURCE_V1_BUCKET_NAME", "", false},
+		{&gen.Layers3D, "GCLOUD_SOURCE_V2_BUCKET_NAME", "", false},
+		{&gen.Images2D, "GCLOUD_UPLOAD_BUCKET_NAME", "POLYMORPH_IMAGE_URL_V1", true},
+		{&gen.Images3D, "GCLOUD_UPLOAD_3D_BUCKET_NAME", "POLYMORPH_IMAGE_URL_V2", true},
+		{&gen.Iframes, "IFRAME_HTMLS_BUCKET_NAME", "", true},
+	}
 
-	if err != nil {
-		log.Errorf("storage.NewClient: %v", err)
+	for _, s := range stores {
+		store, err := storage.Open(ctx, os.Getenv(s.location), os.Getenv(s.publicURL))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.location, err)
+		}
+		if s.output {
+			store = storage.NewCachedStore(store, cacheSize, rendered)
+		}
+		*s.store = store
 	}
-	defer client.Close()
 
-	bucket := client.Bucket(iframeHtmlsBucketName)
-	exists, err := bucket.Object(imageURL).If(storage.Conditions{DoesNotExist: true}).NewReader(ctx)
+	gen.Placeholder2D = os.Getenv("PLACEHOLDER_IMAGE_URL_V1")
+	gen.Placeholder3D = os.Getenv("PLACEHOLDER_IMAGE_URL_V2")
 
-	return exists != nil
+	if renditions := os.Getenv("RENDITIONS"); renditions != "" {
+		var err error
+		if gen.Renditions, err = ParseRenditions(renditions); err != nil {
+			return nil, fmt.Errorf("RENDITIONS: %w", err)
+		}
+	}
 
-}
+	layerCacheMB := DEFAULT_LAYER_CACHE_MB
+	if mb, err := strconv.Atoi(os.Getenv("LAYER_CACHE_MB")); err == nil && mb >= 0 {
+		layerCacheMB = mb
+	}
+	if layerCacheMB > 0 {
+		gen.LayerCache = NewLayerCache(layerCacheMB)
+	}
+	gen.LayerConcurrency, _ = strconv.Atoi(os.Getenv("LAYER_CONCURRENCY"))
+	gen.LayerIndexTTL, _ = time.ParseDuration(os.Getenv("LAYER_INDEX_TTL"))
+	gen.RenderTimeout, _ = time.ParseDuration(os.Getenv("RENDER_TIMEOUT"))
 
-//func cidExists(animationURL *string) string {
-//	ctx := context.Background()
-//	client, err := storage.NewClient(ctx)
-//
-//	if err != nil {
-//		log.Errorf("storage.NewClient: %v", err)
-//	}
-//	defer client.Close()
-//
-//	//var builder cid.V0Builder
-//
-//	f, err := os.Create("iframe-go.html")
-//	if err != nil {
-//		fmt.Println("os.Create: %v", err)
-//	}
-//
-//	bucketReader, err := client.Bucket(IFRAME_HTMLS_BUCKET_NAME).Object(*animationURL).NewReader(ctx)
-//
-//	defer bucketReader.Close()
-//
-//	if _, err := io.Copy(f, bucketReader); err != nil {
-//		fmt.Println("io.Copy: %v", err)
-//	}
-//
-//	if err = f.Close(); err != nil {
-//		fmt.Errorf("f.Close: %v", err)
-//	}
-//
-//	pref := cid.Prefix{
-//		Version:  1,
-//		Codec: mc.Raw,
-//		MhType:   mh.SHA2_256,
-//		MhLength: -1, // default length
-//	}
-//
-//	dat, err := ioutil.ReadFile("iframe-go.html")
-//
-//	c, err := pref.Sum(dat)
-//
-//	fmt.Println("Created CID: ", c.Hash().B58String())
-//	return c.String()
-//
-//	//var msg, _ = ioutil.ReadAll(f)
-//	//
-//	//c, _ := cid.V0Builder.Sum(builder, msg)
-//	//
-//	//return c.Hash().B58String()
-//}
-
-func combineRemoteImages(bucket *storage.BucketHandle, basePath string, overlayPaths ...string) *image.NRGBA {
-
-	ctx := context.Background()
-
-	baseReader, err := bucket.Object(basePath).NewReader(ctx)
+	cidVersion, err := ipfs.CIDVersionFromEnv()
 	if err != nil {
-		log.Fatalf("failed to open image: %v", err)
+		return nil, err
 	}
-	defer baseReader.Close()
-	base, err := imaging.Decode(baseReader)
+	pinner, err := ipfs.NewPinnerFromEnv(cidVersion)
 	if err != nil {
-		log.Fatalf("failed to decode image: %v", err)
+		return nil, err
+	}
+	gen.Pinner = ipfs.NewCachedPinner(pinner, ipfs.DEFAULT_PIN_CACHE_SIZE)
+	gen.CIDVersion = cidVersion
+	gen.cids = cache.NewLRU(cacheSize)
+	gen.PublishRenditions, _ = strconv.ParseBool(os.Getenv("PUBLISH_RENDITIONS"))
+	gen.IPFSGateway = os.Getenv("IPFS_GATEWAY_URL")
+	gen.LegacyFields, _ = strconv.ParseBool(os.Getenv("METADATA_LEGACY_FIELDS"))
+
+	if os.Getenv("PUBLISHER") == "arweave" {
+		uploader, err := arweave.NewUploaderFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		gen.Arweave = uploader
 	}
-	dst := imaging.New(IMG_SIZE, IMG_SIZE, color.NRGBA{0, 0, 0, 0})
-	dst = imaging.Paste(dst, base, image.Pt(0, 0))
 
-	for _, op := range overlayPaths {
-		r, err := bucket.Object(op).NewReader(ctx)
+	if location := os.Getenv("RENDER_LOCK_BUCKET_NAME"); location != "" {
+		store, err := storage.Open(ctx, location, "")
 		if err != nil {
-			log.Fatalf("failed to open image: %v", err)
+			return nil, fmt.Errorf("RENDER_LOCK_BUCKET_NAME: %w", err)
 		}
-		defer r.Close()
-		o, err := imaging.Decode(r)
+		ttl, _ := time.ParseDuration(os.Getenv("RENDER_LOCK_TTL"))
+		locker, err := storage.NewLeaseLocker(store, ttl)
 		if err != nil {
-			log.Fatalf("failed to open image: %v", err)
+			return nil, fmt.Errorf("RENDER_LOCK_BUCKET_NAME: %w", err)
 		}
-		dst = imaging.Overlay(dst, o, image.Pt(0, 0), 1)
+		gen.Locker = locker
 	}
 
-	return dst
+	return &gen, nil
 }
 
-func reverseGenesOrder(genes []string) []string {
-	res := make([]string, 0, len(genes))
-	for i := len(genes) - 1; i >= 0; i-- {
-		res = append(res, genes[i])
+// imagesExist tells whether every rendition of both images of the genes is rendered.
+func (gen *Generator) imagesExist(ctx context.Context, genes []string) (bool, error) {
+	for _, v2 := range []bool{false, true} {
+		images := gen.Images2D
+		if v2 {
+			images = gen.Images3D
+		}
+		missing, err := gen.missingRenditions(ctx, v2, images, genes)
+		if err != nil || len(missing) > 0 {
+			return false, err
+		}
 	}
-	return res
+	return true, nil
 }
 
-func saveToGCloud(i *image.NRGBA, name string, bucketName string) {
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+func (gen *Generator) missingRenditions(ctx context.Context, v2 bool, images storage.ObjectStore, genes []string) ([]Rendition, error) {
+	genesKey := gen.renderKey(genes, v2)
 
-	if err != nil {
-		log.Errorf("storage.NewClient: %v", err)
+	var missing []Rendition
+	for _, rendition := range gen.renditions() {
+		exists, err := images.Exists(ctx, rendition.Key(genesKey))
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, rendition)
+		}
 	}
-	defer client.Close()
-
-	bucket := client.Bucket(bucketName).Object(name).NewWriter(ctx)
-	// f, err := imaging.FormatFromFilename(name)
-	// if err != nil {
-	// 	log.Errorf("Format from filename: %v", err)
-	// }
-	err = imaging.Encode(bucket, i, imaging.JPEG, imaging.JPEGQuality(80))
+	return missing, nil
+}
 
-	if err != nil {
-		log.Errorf("Upload: %v", err)
+func (gen *Generator) renditions() []Rendition {
+	if len(gen.Renditions) == 0 {
+		return DEFAULT_RENDITIONS
 	}
+	return gen.Renditions
+}
 
-	if err = bucket.Close(); err != nil {
-		log.Errorf("Writer.Close: %v", err)
+// RenditionURLs returns the URLs of the renditions of the genes by name, for the 2D and the 3D images,
+// at the current art version.
+func (gen *Generator) RenditionURLs(genes []string) (map[string]string, map[string]string) {
+	key2D, key3D := gen.renderKey(genes, false), gen.renderKey(genes, true)
+	urls2D, urls3D := map[string]string{}, map[string]string{}
+	for _, rendition := range gen.renditions() {
+		urls2D[rendition.Name] = gen.Images2D.URL(rendition.Key(key2D))
+		urls3D[rendition.Name] = gen.Images3D.URL(rendition.Key(key3D))
 	}
+	return urls2D, urls3D
+}
 
+// renderImages renders the missing images of the genes, or every rendition when force is set. Concurrent calls
+// for the same render key share one render, which isn't bound to the context of the first caller but to RenderTimeout.
+func (gen *Generator) renderImages(ctx context.Context, key string, genes []string, force bool) error {
+	_, err, _ := gen.flight.Do(fmt.Sprintf("images/%s/%t", key, force), func() (interface{}, error) {
+		timeout := gen.RenderTimeout
+		if timeout <= 0 {
+			timeout = DEFAULT_RENDER_TIMEOUT
+		}
+		renderCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return nil, gen.renderMissingImages(renderCtx, key, genes, force)
+	})
+	return err
 }
 
-func generateAndSaveImage(genes []string, gCloudSourceBucket string, gCloudUploadBucket string) {
-	// Reverse
-	revGenes := reverseGenesOrder(genes)
+func (gen *Generator) renderMissingImages(ctx context.Context, key string, genes []string, force bool) error {
+	if gen.Locker != nil {
+		unlock, err := gen.Locker.Lock(ctx, "images/"+key)
+		if err != nil {
+			return fmt.Errorf("locking %s: %w", key, err)
+		}
+		defer unlock()
+	}
 
-	f := make([]string, len(genes))
+	for _, s := range []struct {
+		v2     bool
+		images storage.ObjectStore
+	}{
+		{false, gen.Images2D},
+		{true, gen.Images3D},
+	} {
+		missing := gen.renditions()
+		if !force {
+			var err error
+			if missing, err = gen.missingRenditions(ctx, s.v2, s.images, genes); err != nil {
+				return err
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		if err := gen.generateAndSaveImage(ctx, genes, s.v2, s.images, missing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	for i, gene := range revGenes {
-		f[i] = fmt.Sprintf("./images/%v/%s.png", i, gene)
+func reverseGenesOrder(genes []string) []string {
+	res := make([]string, 0, len(genes))
+	for i := len(genes) - 1; i >= 0; i-- {
+		res = append(res, genes[i])
 	}
+	return res
+}
 
-	ctx := context.Background()
-	client, err := storage.NewClient(ctx)
+func saveImage(ctx context.Context, images storage.ObjectStore, i image.Image, genesKey string, rendition Rendition) error {
+	name := rendition.Key(genesKey)
 
-	if err != nil {
-		log.Errorf("storage.NewClient: %v", err)
+	buf := &bytes.Buffer{}
+	if err := encodeRendition(buf, i, rendition); err != nil {
+		return fmt.Errorf("encoding %s: %w", name, err)
 	}
-	defer client.Close()
-
-	bucket := client.Bucket(gCloudSourceBucket)
+	if err := images.Put(ctx, name, buf, ImageEncoders[rendition.Format].ContentType); err != nil {
+		return fmt.Errorf("uploading %s: %w", name, err)
+	}
+	return nil
+}
 
-	i := combineRemoteImages(bucket, f[0], f[1:]...)
+// encodeRendition scales the composed image down to the size of the rendition and encodes it.
+func encodeRendition(w io.Writer, i image.Image, rendition Rendition) error {
+	img := i
+	if rendition.Size < IMG_SIZE {
+		img = imaging.Resize(i, rendition.Size, rendition.Size, imaging.Lanczos)
+	}
+	return ImageEncoders[rendition.Format].Encode(w, img, rendition.Quality)
+}
 
-	b := strings.Builder{}
+// generateAndSaveImage composes the layers of the genes and saves the renditions, from the 3D still layers when v2 is set.
+// The layers are composed once with the background and once without, when a rendition is transparent.
+func (gen *Generator) generateAndSaveImage(ctx context.Context, genes []string, v2 bool, images storage.ObjectStore, renditions []Rendition) error {
+	genesKey := gen.renderKey(genes, v2)
+	composed := map[bool]*image.RGBA{}
+	defer func() {
+		for _, i := range composed {
+			releaseCanvas(i)
+		}
+	}()
+
+	for _, rendition := range renditions {
+		i, ok := composed[rendition.Transparent]
+		if !ok {
+			var err error
+			if i, err = gen.composeGenes(ctx, genes, v2, rendition.Transparent); err != nil {
+				return err
+			}
+			composed[rendition.Transparent] = i
+		}
 
-	for _, gene := range genes {
-		b.WriteString(gene)
+		if err := saveImage(ctx, images, i, genesKey, rendition); err != nil {
+			return err
+		}
 	}
 
-	b.WriteString(".jpg") // Finish with jpg extension
-
-	saveToGCloud(i, b.String(), gCloudUploadBucket)
+	return nil
 }
 
 type ImageURLs struct {
@@ -209,8 +365,9 @@ type ImageURLs struct {
 }
 
 type Badge struct {
-	Name string
-	URL  string
+	Name  string
+	Title string
+	URL   string
 }
 
 type TemplateHTML struct {
@@ -218,119 +375,90 @@ type TemplateHTML struct {
 	Badges  []Badge
 }
 
-// generateAndSaveToIpfs Generates the polymorph animation url and uploads it to IPFS
-func generateAndSaveToIpfs(iframeURL *string, image2DURL *string, image3DURL *string, badges *[]string) (cid string) {
-
-	htmlBadges := make([]Badge, len(*badges))
+// generateAndPublishIframe Generates the polymorph animation url and publishes it to Arweave or IPFS, returning its
+// ar:// or ipfs:// URI, or an empty string when the generator publishes nothing.
+// Concurrent calls for the same iframe share a single upload and pin.
+func (gen *Generator) generateAndPublishIframe(ctx context.Context, iframeURL string, image2DURL string, image3DURL string, badges []BadgeMetadata, meta ipfs.PinMetadata) (string, error) {
 
-	baseBadgeUrl := os.Getenv("BADGE_BASE_URL")
+	htmlBadges := make([]Badge, len(badges))
 
-	for i, badge := range *badges {
-		htmlBadges[i].Name = badge
-		htmlBadges[i].URL = fmt.Sprintf("%v%s.svg", baseBadgeUrl, badge)
-		(*badges)[i] = fmt.Sprintf("%v%s.svg", baseBadgeUrl, badge)
+	for i, badge := range badges {
+		htmlBadges[i].Name = badge.Name
+		htmlBadges[i].Title = badge.DisplayName
+		htmlBadges[i].URL = badge.Image
 	}
 
-	gcloudExists := objectExists(*iframeURL)
-
-	tmpl := template.Must(template.ParseFiles("./serverless_function_source_code/index.html"))
+	tmpl, err := template.ParseFS(assets.Files(), assets.IFRAME_TEMPLATE_FILE)
+	if err != nil {
+		return "", fmt.Errorf("parsing iframe template: %w", err)
+	}
 	data := TemplateHTML{
-		ImgUrls: ImageURLs{*image2DURL, *image3DURL},
+		ImgUrls: ImageURLs{image2DURL, image3DURL},
 		Badges:  htmlBadges,
 	}
 
-	if !gcloudExists { // If false, write the animation html to GCloud
-		ctx := context.Background()
-		client, err := storage.NewClient(ctx)
-
-		iframeHtmlsBucketName := os.Getenv("IFRAME_HTMLS_BUCKET_NAME")
-
-		if err != nil {
-			log.Errorf("storage.NewClient: %v", err)
-		}
-		defer client.Close()
-
-		tpl := &bytes.Buffer{}
-
-		bucket := client.Bucket(iframeHtmlsBucketName).Object(*iframeURL).NewWriter(ctx)
-
-		if err := tmpl.Execute(tpl, data); err != nil {
-			fmt.Println(err)
-		}
-
-		bucket.Write(tpl.Bytes())
-		if err := bucket.Close(); err != nil {
-			fmt.Println("createFile: unable to close bucket %q, file %q: %v", err)
-		}
+	html := &bytes.Buffer{}
+	if err := tmpl.Execute(html, data); err != nil {
+		return "", fmt.Errorf("executing iframe template: %w", err)
 	}
 
-	f, err := os.Create("/tmp/iframe-go.html")
+	meta.Name = IFRAME_FILE_NAME
+	sum := sha256.Sum256(html.Bytes())
+	res, err, _ := gen.flight.Do("iframe/"+iframeURL+"/"+hex.EncodeToString(sum[:]), func() (interface{}, error) {
+		return gen.publishIframe(context.Background(), iframeURL, html.Bytes(), meta)
+	})
 	if err != nil {
-		log.Println("create file: ", err.Error())
+		return "", err
 	}
-	err = tmpl.Execute(f, data)
+	return res.(string), nil
+}
 
-	if err != nil {
-		log.Print("Error executing html animation template: ", err.Error())
-	}
-	err = f.Close()
-	if err != nil {
-		log.Println("Error closing file %v %s. Original error ", f.Name(), err.Error())
+// publishIframe uploads the iframe to Arweave when the generator has an uploader, or pins it to IPFS.
+func (gen *Generator) publishIframe(ctx context.Context, key string, html []byte, meta ipfs.PinMetadata) (string, error) {
+	if gen.Arweave != nil {
+		sum := sha256.Sum256(html)
+		if id := gen.arweaveIndexed(ctx, key, hex.EncodeToString(sum[:])); id != "" {
+			return ARWEAVE_SCHEME + id, nil
+		}
+		gen.saveIframe(ctx, key, html)
+		return gen.uploadToArweave(ctx, key, html, "text/html", meta)
 	}
 
-	PinataApiKey := os.Getenv("PINATA_API_KEY")
-	PinataSecretKey := os.Getenv("PINATA_SECRET_KEY")
-
-	method := "POST"
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
-	file, errFile1 := os.Open("/tmp/iframe-go.html")
-	defer file.Close()
-	part1, errFile1 := writer.CreateFormFile("file", filepath.Base("/tmp/iframe-go.html"))
-
-	_, errFile1 = io.Copy(part1, file)
-	if errFile1 != nil {
-		fmt.Println(errFile1)
+	cid, err := gen.saveAndPinIframe(ctx, key, html, meta)
+	if err != nil || cid == "" {
+		return "", err
 	}
-	err = writer.Close()
-	if err != nil {
-		fmt.Println(err)
-	}
-
-	httpClient := &http.Client{}
+	return IPFS_SCHEME + cid, nil
+}
 
-	req, err := http.NewRequest(method, IpfsBaseUploadURL, payload)
-	if err != nil {
-		log.Fatal(err)
+// saveAndPinIframe computes the CID of the iframe and, unless the index already has it for the key, writes the iframe
+// to the iframes store, replacing the one of a previous CID, and pins it when it's not pinned yet. The CID of an unchanged iframe is thus
+// returned without any upload.
+func (gen *Generator) saveAndPinIframe(ctx context.Context, key string, html []byte, meta ipfs.PinMetadata) (string, error) {
+	if gen.Pinner == nil {
+		return "", nil
 	}
 
-	req.Header.Add("pinata_api_key", PinataApiKey)
-	req.Header.Add("pinata_secret_api_key", PinataSecretKey)
-	req.Header.Set("Connection", "keep-alive")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	//req.Header.Set("Content-Length", string(payload.Len()))
-
-	// Send HTTP Post request to Pinata
-	resp, err := httpClient.Do(req)
+	cid, err := ipfs.ComputeCID(html, gen.CIDVersion)
 	if err != nil {
-		fmt.Println(err)
+		return "", err
 	}
-	defer resp.Body.Close()
 
-	decoder := json.NewDecoder(resp.Body)
-
-	pinataResponse := pinataBodyResponse{}
-
-	err = decoder.Decode(&pinataResponse)
+	if indexed, err := gen.indexedCID(ctx, key); err != nil {
+		log.Errorf("Reading the CID of iframe %s: %v", key, err)
+	} else if indexed == cid {
+		return cid, nil
+	}
 
-	httpClient.CloseIdleConnections()
+	gen.saveIframe(ctx, key, html)
+	return gen.pinContent(ctx, key, cid, html, meta)
+}
 
-	e := os.Remove("/tmp/iframe-go.html")
-	if e != nil {
-		log.Fatal(e)
+// saveIframe writes the iframe to the iframes store. It's only called when the index doesn't have the content of the
+// iframe, so a stored iframe of an older render is overwritten. A failure is only logged, the published iframe
+// doesn't depend on it.
+func (gen *Generator) saveIframe(ctx context.Context, key string, html []byte) {
+	if err := gen.Iframes.Put(ctx, key, bytes.NewReader(html), "text/html"); err != nil {
+		log.Errorf("Uploading iframe %s: %v", key, err)
 	}
-
-	return pinataResponse.IPFSHash
 }
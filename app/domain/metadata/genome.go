@@ -0,0 +1,180 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/polymorph-metadata/app/config"
+)
+
+var ErrInvalidGenome = errors.New("genome must be a non-negative integer")
+var ErrShortGenome = errors.New("genome is too short")
+
+// GeneOutOfRangeError is returned when the raw value of a slot has no matching variant.
+type GeneOutOfRangeError struct {
+	Slot  string
+	Value int
+	Count int
+}
+
+func (e *GeneOutOfRangeError) Error() string {
+	return fmt.Sprintf("gene %s: raw value %d is out of range, expected 0-%d", e.Slot, e.Value, e.Count-1)
+}
+
+// DecodeError is returned when a genome can't be decoded. Err is ErrInvalidGenome, ErrShortGenome or
+// a *GeneOutOfRangeError.
+type DecodeError struct {
+	Genome string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("genome %s: %v", e.Genome, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodedGenome holds the variant index of every trait slot of a polymorph.
+type DecodedGenome struct {
+	Character  Gene
+	Background Gene
+	Pants      Gene
+	Torso      Gene
+	Footwear   Gene
+	Eyewear    Gene
+	Headwear   Gene
+	RightHand  Gene
+	LeftHand   Gene
+	// Extra holds the genes of slots added through the slot table that have no field of their own
+	Extra map[string]Gene `json:",omitempty"`
+
+	layout config.GeneLayout
+}
+
+// DecodeGenome splits the genome into its genes using the default gene layout.
+func DecodeGenome(genome *big.Int) (DecodedGenome, error) {
+	return DecodeGenomeWithLayout(config.DEFAULT_GENE_LAYOUT, genome)
+}
+
+// DecodeGenomeWithLayout splits the genome into the genes of the slot table. Every slot is read
+// from the right end of the genome, as described in the README.
+func DecodeGenomeWithLayout(layout config.GeneLayout, genome *big.Int) (DecodedGenome, error) {
+	d := DecodedGenome{layout: layout}
+
+	if genome == nil || genome.Sign() < 0 {
+		return d, &DecodeError{Genome: fmt.Sprint(genome), Err: ErrInvalidGenome}
+	}
+
+	g := genome.String()
+	if len(g) < layout.GenomeLength() {
+		return d, &DecodeError{Genome: g, Err: fmt.Errorf("%w: got %d digits, expected at least %d", ErrShortGenome, len(g), layout.GenomeLength())}
+	}
+
+	for _, slot := range layout {
+		gene, err := decodeGene(g, slot)
+		if err != nil {
+			return DecodedGenome{layout: layout}, &DecodeError{Genome: g, Err: err}
+		}
+		d.SetGene(slot.Name, gene)
+	}
+
+	return d, nil
+}
+
+// Layout returns the slot table the genome was decoded with.
+func (d *DecodedGenome) Layout() config.GeneLayout {
+	if d.layout == nil {
+		return config.DEFAULT_GENE_LAYOUT
+	}
+	return d.layout
+}
+
+// Gene returns the gene of the slot with the given name.
+func (d *DecodedGenome) Gene(slot string) Gene {
+	if g := d.field(slot); g != nil {
+		return *g
+	}
+	return d.Extra[slot]
+}
+
+// SetGene sets the gene of the slot with the given name.
+func (d *DecodedGenome) SetGene(slot string, gene Gene) {
+	if g := d.field(slot); g != nil {
+		*g = gene
+		return
+	}
+	if d.Extra == nil {
+		d.Extra = map[string]Gene{}
+	}
+	d.Extra[slot] = gene
+}
+
+func (d *DecodedGenome) field(slot string) *Gene {
+	switch slot {
+	case "character":
+		return &d.Character
+	case "background":
+		return &d.Background
+	case "pants":
+		return &d.Pants
+	case "torso":
+		return &d.Torso
+	case "footwear":
+		return &d.Footwear
+	case "eyewear":
+		return &d.Eyewear
+	case "headwear":
+		return &d.Headwear
+	case "right hand":
+		return &d.RightHand
+	case "left hand":
+		return &d.LeftHand
+	}
+	return nil
+}
+
+// Genome encodes the genes back into the canonical genome. The canonical genome carries a leading 1
+// so that the gene digits survive leading zero genes, e.g. 1 00 00 ... 08 for a bare Vitalik.
+func (d DecodedGenome) Genome() (*big.Int, error) {
+	layout := d.Layout()
+	length := layout.GenomeLength()
+	digits := []byte(strings.Repeat("0", length))
+
+	for _, slot := range layout {
+		gene := d.Gene(slot.Name)
+		if int(gene) < 0 || int(gene) >= slot.Count {
+			return nil, &GeneOutOfRangeError{Slot: slot.Name, Value: int(gene), Count: slot.Count}
+		}
+		copy(digits[length-slot.Offset-slot.Width:], gene.toPath(slot.Width))
+	}
+
+	genome, _ := new(big.Int).SetString("1"+string(digits), 10)
+	return genome, nil
+}
+
+// Decode parses the genome string and decodes it with the given gene layout.
+func (g *Genome) Decode(layout config.GeneLayout) (DecodedGenome, error) {
+	genome, ok := new(big.Int).SetString(string(*g), 10)
+	if !ok {
+		return DecodedGenome{}, &DecodeError{Genome: fmt.Sprintf("%q", string(*g)), Err: ErrInvalidGenome}
+	}
+	return DecodeGenomeWithLayout(layout, genome)
+}
+
+// decodeGene reads the digits of the slot, counting its offset from the right end of the genome.
+func decodeGene(g string, slot config.GeneSlot) (Gene, error) {
+	end := len(g) - slot.Offset
+	value, err := strconv.Atoi(g[end-slot.Width : end])
+	if err != nil {
+		return 0, fmt.Errorf("gene %s: %w", slot.Name, err)
+	}
+	if value >= slot.Count {
+		return 0, &GeneOutOfRangeError{Slot: slot.Name, Value: value, Count: slot.Count}
+	}
+	return Gene(value), nil
+}
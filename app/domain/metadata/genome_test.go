@@ -0,0 +1,124 @@
+package metadata
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/polymorph-metadata/app/config"
+)
+
+func TestGenomeDecode(t *testing.T) {
+	tests := []struct {
+		name      string
+		genome    string
+		character Gene
+		leftHand  Gene
+		pants     Gene
+		canonical string
+		err       error
+	}{
+		// 1, left hand 07, right hand 03, headwear 05, eyewear 02, footwear 10, torso 20, pants 30, background 11, character 08
+		{name: "canonical", genome: "1070305021020301108", character: 8, leftHand: 7, pants: 30, canonical: "1070305021020301108"},
+		{name: "leading digits", genome: "98070305021020301108", character: 8, leftHand: 7, pants: 30, canonical: "1070305021020301108"},
+		{name: "zero genes", genome: "1000000000000000000", canonical: "1000000000000000000"},
+		// the leading 1 keeps the digits of a leading zero gene, which are lost without it
+		{name: "without leading 1", genome: "070305021020301108", err: ErrShortGenome},
+		{name: "character out of range", genome: "1070305021020301111", err: &GeneOutOfRangeError{}},
+		{name: "pants out of range", genome: "1070305021020331108", err: &GeneOutOfRangeError{}},
+		{name: "short", genome: "12345", err: ErrShortGenome},
+		{name: "not a number", genome: "10703x5021020301108", err: ErrInvalidGenome},
+		{name: "negative", genome: "-1070305021020301108", err: ErrInvalidGenome},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Genome(tt.genome)
+			decoded, err := g.Decode(config.DEFAULT_GENE_LAYOUT)
+
+			if tt.err != nil {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Errorf("Decode error = %v, want a DecodeError", err)
+				}
+				var outOfRange *GeneOutOfRangeError
+				if _, ok := tt.err.(*GeneOutOfRangeError); ok && !errors.As(err, &outOfRange) {
+					t.Fatalf("Decode error = %v, want a GeneOutOfRangeError", err)
+				} else if !ok && !errors.Is(err, tt.err) {
+					t.Fatalf("Decode error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if decoded.Character != tt.character || decoded.LeftHand != tt.leftHand || decoded.Pants != tt.pants {
+				t.Errorf("character, left hand, pants = %d, %d, %d, want %d, %d, %d", decoded.Character, decoded.LeftHand, decoded.Pants, tt.character, tt.leftHand, tt.pants)
+			}
+
+			genome, err := decoded.Genome()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if genome.String() != tt.canonical {
+				t.Errorf("Genome() = %s, want %s", genome, tt.canonical)
+			}
+		})
+	}
+}
+
+func TestGenomeEncodeOutOfRange(t *testing.T) {
+	g := Genome("1070305021020301108")
+	decoded, err := g.Decode(config.DEFAULT_GENE_LAYOUT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded.SetGene("headwear", 31)
+	var outOfRange *GeneOutOfRangeError
+	if _, err := decoded.Genome(); !errors.As(err, &outOfRange) || outOfRange.Slot != "headwear" {
+		t.Errorf("Genome() error = %v, want a GeneOutOfRangeError of headwear", err)
+	}
+}
+
+func TestGenomeCustomLayout(t *testing.T) {
+	layout := config.GeneLayout{
+		{Name: "character", Offset: 0, Width: 1, Count: 5, List: "character", Layer: 1},
+		{Name: "aura", Offset: 1, Width: 3, Count: 120, List: "aura", Layer: 0},
+	}
+
+	tests := []struct {
+		genome    string
+		character Gene
+		aura      Gene
+		err       bool
+	}{
+		{genome: "11194", character: 4, aura: 119},
+		{genome: "10003", character: 3, aura: 0},
+		{genome: "11204", err: true},
+		{genome: "11195", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.genome, func(t *testing.T) {
+			g := Genome(tt.genome)
+			decoded, err := g.Decode(layout)
+			if tt.err {
+				if err == nil {
+					t.Fatal("Decode succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Character != tt.character || decoded.Gene("aura") != tt.aura {
+				t.Errorf("character, aura = %d, %d, want %d, %d", decoded.Character, decoded.Gene("aura"), tt.character, tt.aura)
+			}
+			genome, err := decoded.Genome()
+			if err != nil || genome.String() != tt.genome {
+				t.Errorf("Genome() = %v, %v, want %s", genome, err, tt.genome)
+			}
+		})
+	}
+}
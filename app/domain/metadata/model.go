@@ -2,9 +2,12 @@ package metadata
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/ipfs"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"math"
@@ -12,21 +15,11 @@ import (
 	"net/http"
 	"os"
 	"strconv"
-	"strings"
+	"time"
 )
 
 const IFRAME_UPLOADED_BASE_URL string = "https://storage.googleapis.com/iframe-htmls-mainnet/"
 const EXTERNAL_URL string = "https://polymorphs.universe.xyz/polymorphs/"
-const GENES_COUNT = 9
-const BACKGROUND_GENE_COUNT int = 12
-const BASE_GENES_COUNT int = 11
-const SHOES_GENES_COUNT int = 25
-const PANTS_GENES_COUNT int = 33
-const TORSO_GENES_COUNT int = 34
-const EYEWEAR_GENES_COUNT int = 13
-const HEAD_GENES_COUNT int = 31
-const WEAPON_RIGHT_GENES_COUNT int = 32
-const WEAPON_LEFT_GENES_COUNT int = 32
 
 type Genome string
 
@@ -37,8 +30,9 @@ type StringAttribute struct {
 }
 
 type IntegerAttribute struct {
-	TraitType string `json:"trait_type"`
-	Value     int    `json:"value"`
+	TraitType   string `json:"trait_type"`
+	Value       int    `json:"value"`
+	DisplayType string `json:"display_type,omitempty"`
 }
 
 type FloatAttribute struct {
@@ -47,200 +41,48 @@ type FloatAttribute struct {
 	DisplayType string  `json:"display_type"`
 }
 
-func (g Gene) toPath() string {
-	if g < 10 {
-		return fmt.Sprintf("0%s", strconv.Itoa(int(g)))
-	}
-
-	return strconv.Itoa(int(g))
-}
-
-func getGeneInt(g string, start, end, count int) int {
-	genomeLen := len(g)
-	geneStr := g[genomeLen+start : genomeLen+end]
-	gene, _ := strconv.Atoi(geneStr)
-	return gene % count
-}
-
-func getWeaponLeftGene(g string) int {
-	return getGeneInt(g, -18, -16, WEAPON_LEFT_GENES_COUNT)
+func (g Gene) toPath(width int) string {
+	return fmt.Sprintf("%0*d", width, int(g))
 }
 
-func getWeaponLeftGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getWeaponLeftGene(g)
-	return StringAttribute{
-		TraitType: "Left Hand",
-		Value:     configService.WeaponLeft[gene],
+// trait returns the trait name of the gene of the slot, read from the list of the slot in the layout,
+// or "" when the layout has no such slot.
+func (d DecodedGenome) trait(configService *config.ConfigService, slot string) string {
+	s := d.Layout().Slot(slot)
+	if s == nil {
+		return ""
 	}
+	return traitName(configService.TraitList(s.List), d.Gene(slot))
 }
 
-func getWeaponLeftGenePath(g string) string {
-	gene := getWeaponLeftGene(g)
-	return Gene(gene).toPath()
+// characterType returns the type of the character, e.g. "Wolf", as the type list has one entry per character.
+func (d DecodedGenome) characterType(configService *config.ConfigService) string {
+	return traitName(configService.Type, d.Gene("character"))
 }
 
-func getWeaponRightGene(g string) int {
-	return getGeneInt(g, -16, -14, WEAPON_RIGHT_GENES_COUNT)
-}
-
-func getWeaponRightGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getWeaponRightGene(g)
-	return StringAttribute{
-		TraitType: "Right Hand",
-		Value:     configService.WeaponRight[gene],
+func traitName(list []string, gene Gene) string {
+	if int(gene) < 0 || int(gene) >= len(list) {
+		return ""
 	}
+	return list[gene]
 }
 
-func getWeaponRightGenePath(g string) string {
-	gene := getWeaponRightGene(g)
-	return Gene(gene).toPath()
-}
-
-func getHeadGene(g string) int {
-	return getGeneInt(g, -14, -12, HEAD_GENES_COUNT)
+func (d DecodedGenome) name(configService *config.ConfigService, tokenId string) string {
+	return fmt.Sprintf("%v #%v", d.trait(configService, "character"), tokenId)
 }
 
-func getHeadGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getHeadGene(g)
-	return StringAttribute{
-		TraitType: "Headwear",
-		Value:     configService.Headwear[gene],
-	}
+func (d DecodedGenome) description(configService *config.ConfigService, tokenId string) string {
+	return fmt.Sprintf("The %v named %v #%v is a citizen of the Polymorph Universe and has a unique genetic code! You can scramble your Polymorph at anytime.", d.characterType(configService), d.trait(configService, "character"), tokenId)
 }
 
-func getHeadGenePath(g string) string {
-	gene := getHeadGene(g)
-	return Gene(gene).toPath()
-}
-
-func getEyewearGene(g string) int {
-	return getGeneInt(g, -12, -10, EYEWEAR_GENES_COUNT)
-}
-
-func getEyewearGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getEyewearGene(g)
-	return StringAttribute{
-		TraitType: "Eyewear",
-		Value:     configService.Eyewear[gene],
-	}
-}
-
-func getEyewearGenePath(g string) string {
-	gene := getEyewearGene(g)
-	return Gene(gene).toPath()
-}
-
-func getShoesGene(g string) int {
-	return getGeneInt(g, -10, -8, SHOES_GENES_COUNT)
-}
-
-func getShoesGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getShoesGene(g)
-	return StringAttribute{
-		TraitType: "Footwear",
-		Value:     configService.Footwear[gene],
-	}
-}
-
-func getShoesGenePath(g string) string {
-	gene := getShoesGene(g)
-	return Gene(gene).toPath()
-}
-
-func getTorsoGene(g string) int {
-	return getGeneInt(g, -8, -6, TORSO_GENES_COUNT)
-}
+// genes returns the gene paths from the top image layer to the bottom one.
+func (d DecodedGenome) genes() []string {
+	layers := d.Layout().ByLayer()
 
-func getTorsoGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getTorsoGene(g)
-	return StringAttribute{
-		TraitType: "Torso",
-		Value:     configService.Torso[gene],
+	res := make([]string, 0, len(layers))
+	for i := len(layers) - 1; i >= 0; i-- {
+		res = append(res, d.Gene(layers[i].Name).toPath(layers[i].Width))
 	}
-}
-
-func getTorsoGenePath(g string) string {
-	gene := getTorsoGene(g)
-	return Gene(gene).toPath()
-}
-
-func getPantsGene(g string) int {
-	return getGeneInt(g, -6, -4, PANTS_GENES_COUNT)
-}
-
-func getPantsGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getPantsGene(g)
-	return StringAttribute{
-		TraitType: "Pants",
-		Value:     configService.Pants[gene],
-	}
-}
-
-func getPantsGenePath(g string) string {
-	gene := getPantsGene(g)
-	return Gene(gene).toPath()
-}
-
-func getBackgroundGene(g string) int {
-	return getGeneInt(g, -4, -2, BACKGROUND_GENE_COUNT)
-}
-
-func getBackgroundGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getBackgroundGene(g)
-	return StringAttribute{
-		TraitType: "Background",
-		Value:     configService.Background[gene],
-	}
-}
-
-func getBackgroundGenePath(g string) string {
-	gene := getBackgroundGene(g)
-	return Gene(gene).toPath()
-}
-
-func getBaseGene(g string) int {
-	return getGeneInt(g, -2, 0, BASE_GENES_COUNT)
-}
-
-func getBaseGeneAttribute(g string, configService *config.ConfigService) StringAttribute {
-	gene := getBaseGene(g)
-	return StringAttribute{
-		TraitType: "Character",
-		Value:     configService.Character[gene],
-	}
-}
-
-func getBaseGenePath(g string) string {
-	gene := getBaseGene(g)
-	return Gene(gene).toPath()
-}
-
-func (g *Genome) name(configService *config.ConfigService, tokenId string) string {
-	gStr := string(*g)
-	gene := getBaseGene(gStr)
-	return fmt.Sprintf("%v #%v", configService.Character[gene], tokenId)
-}
-
-func (g *Genome) description(configService *config.ConfigService, tokenId string) string {
-	gStr := string(*g)
-	gene := getBaseGene(gStr)
-	return fmt.Sprintf("The %v named %v #%v is a citizen of the Polymorph Universe and has a unique genetic code! You can scramble your Polymorph at anytime.", configService.Type[gene], configService.Character[gene], tokenId)
-}
-
-func (g *Genome) genes() []string {
-	gStr := string(*g)
-
-	res := make([]string, 0, GENES_COUNT)
-
-	res = append(res, getWeaponRightGenePath(gStr))
-	res = append(res, getWeaponLeftGenePath(gStr))
-	res = append(res, getHeadGenePath(gStr))
-	res = append(res, getEyewearGenePath(gStr))
-	res = append(res, getTorsoGenePath(gStr))
-	res = append(res, getPantsGenePath(gStr))
-	res = append(res, getShoesGenePath(gStr))
-	res = append(res, getBaseGenePath(gStr))
-	res = append(res, getBackgroundGenePath(gStr))
 
 	return res
 }
@@ -260,39 +102,38 @@ func getRankAttribute(rank int) IntegerAttribute {
 	}
 }
 
-func (g *Genome) attributes(configService *config.ConfigService) []interface{} {
-	gStr := string(*g)
+// backgroundColor returns the background_color of the background variant, or "" when config.json has none.
+func (d DecodedGenome) backgroundColor(configService *config.ConfigService) string {
+	if background := int(d.Gene("background")); background < len(configService.BackgroundColors) {
+		return configService.BackgroundColors[background]
+	}
+	return ""
+}
+
+func getBadgeCountAttribute(count int) IntegerAttribute {
+	return IntegerAttribute{
+		TraitType:   "Badge Count",
+		DisplayType: "number",
+		Value:       count,
+	}
+}
 
+func (d DecodedGenome) attributes(configService *config.ConfigService) []interface{} {
 	res := []interface{}{}
-	res = append(res, getBaseGeneAttribute(gStr, configService))
-	res = append(res, getShoesGeneAttribute(gStr, configService))
-	res = append(res, getPantsGeneAttribute(gStr, configService))
-	res = append(res, getTorsoGeneAttribute(gStr, configService))
-	res = append(res, getEyewearGeneAttribute(gStr, configService))
-	res = append(res, getHeadGeneAttribute(gStr, configService))
-	res = append(res, getWeaponLeftGeneAttribute(gStr, configService))
-	res = append(res, getWeaponRightGeneAttribute(gStr, configService))
-	res = append(res, getBackgroundGeneAttribute(gStr, configService))
+	for _, slot := range d.Layout() {
+		res = append(res, StringAttribute{
+			TraitType: slot.TraitType,
+			Value:     d.trait(configService, slot.Name),
+		})
+	}
 	//res = append(res, getRarityScoreAttribute(rarityResponse.RarityScore))
 	//res = append(res, getRankAttribute(rarityResponse.Rank))
 
 	return res
 }
 
-func badgeGeneContains(s string, list []string) bool {
-	for _, b := range list {
-		if b == s {
-			return true
-		}
-	}
-	return false
-}
-
-func hasLeftOrRightHandRequirement(leftHand *string, rightHand *string, list []string) bool {
-	return badgeGeneContains(*leftHand, list) || badgeGeneContains(*rightHand, list)
-}
-
-func getVirginScrambledBadges(id *big.Int) (isVirgin bool, hasSingleTraitScrambledBadge bool) {
+// getScrambleHistory reads the genome changes of the token from the subgraph of POLYMORPH_V2_THE_GRAPH_HTTP.
+func getScrambleHistory(id *big.Int) (*ScrambleHistory, error) {
 
 	jsonData := map[string]string{
 		"query": `
@@ -306,173 +147,208 @@ func getVirginScrambledBadges(id *big.Int) (isVirgin bool, hasSingleTraitScrambl
 	}
 	jsonValue, _ := json.Marshal(jsonData)
 
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
 
 	polymorphV2TheGraphHttp := os.Getenv("POLYMORPH_V2_THE_GRAPH_HTTP")
-
 	if polymorphV2TheGraphHttp == "" {
-		log.Error("Couldn't get the env variable for the polymorph-v2-the-graph-http")
+		return nil, errors.New("POLYMORPH_V2_THE_GRAPH_HTTP is not set")
 	}
 
 	req, err := http.NewRequest("POST", polymorphV2TheGraphHttp, bytes.NewBuffer(jsonValue))
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		fmt.Println(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the graph: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
 
 	var jsonMap map[string]map[string][]map[string]string
-	json.Unmarshal([]byte(body), &jsonMap)
+	if err := json.Unmarshal(body, &jsonMap); err != nil {
+		return nil, fmt.Errorf("the graph: %w", err)
+	}
 
 	entities := jsonMap["data"]["tokenMorphedEntities"]
-
-	virginBadge := true
-	singleTrait := false
-
 	lenEntities := len(entities)
 
 	// this means that the last transaction is randomizeGenome() => neither virgin badge nor single trait scrambled badge
-	if len(entities) > 0 && entities[lenEntities-1]["priceForGenomeChange"] == "10000000000000000" {
-		virginBadge = false
-		singleTrait = false
-		return virginBadge, singleTrait
-	} else if len(entities) > 0 {
-		virginBadge = false
-		singleTrait = true
-		return virginBadge, singleTrait
+	if lenEntities > 0 && entities[lenEntities-1]["priceForGenomeChange"] == "10000000000000000" {
+		return &ScrambleHistory{}, nil
+	} else if lenEntities > 0 {
+		return &ScrambleHistory{SingleTraitScrambled: true}, nil
 	}
 
-	return virginBadge, singleTrait
+	return &ScrambleHistory{NeverScrambled: true}, nil
 }
 
-func assignBadges(id string, polymorphGenesList *[]string, badgesJsonMap *map[string][]string) *[]string {
-
-	iTokenId, _ := strconv.Atoi(id)
-
-	var badges []string
-	var hasBadge bool
-
-	lightsabers := []string{"08", "13", "14", "15", "20", "25", "26"}
+// assignBadges evaluates the badges of the token. When the scramble history can't be read, it is logged and
+// the badges that depend on it are left out.
+func assignBadges(id string, decoded DecodedGenome, badgesConfig *config.BadgeConfig) *[]string {
+	var history *ScrambleHistory
 
-	isVirgin, singleTrait := getVirginScrambledBadges(big.NewInt(int64(iTokenId)))
-
-	if isVirgin {
-		badges = append(badges, "never-scrambled")
-	}
-	if singleTrait {
-		badges = append(badges, "single-trait-scrambled")
-	}
-
-	leftHandGene := (*polymorphGenesList)[7]
-	rightHandGene := (*polymorphGenesList)[8]
-
-	// Any combination of double-degen swords and degen swords should be akimbo
-	if badgeGeneContains(leftHandGene, lightsabers) && badgeGeneContains(rightHandGene, lightsabers) {
-		badges = append(badges, "akimbo")
-	} else if leftHandGene != "00" && rightHandGene != "00" && leftHandGene == rightHandGene {
-		badges = append(badges, "akimbo")
-	}
-
-	numBadgeRequirements := 7 // All badge requirements are 9, but we check left and right weapons separately
-
-	for badge, geneReqs := range *badgesJsonMap {
-		hasBadge = true
-		// The new logic will be that left hand requirement and right hand requirement should be with ORs
-		hasEitherLeftHandOrRightHand := hasLeftOrRightHandRequirement(&leftHandGene, &rightHandGene, geneReqs[7:9])
-		for i := 0; i < numBadgeRequirements; i++ {
-			if geneReqs[i] != "**" {
-				requirements := strings.Split(geneReqs[i], "/")
-				geneHasBadgeRequirement := badgeGeneContains((*polymorphGenesList)[i], requirements)
-				if !geneHasBadgeRequirement {
-					hasBadge = false
-					break
-				}
-			}
-		}
-		if hasBadge && hasEitherLeftHandOrRightHand {
-			badges = append(badges, badge)
+	if badgesConfig.NeedsHistory() {
+		iTokenId, _ := strconv.Atoi(id)
+		var err error
+		if history, err = getScrambleHistory(big.NewInt(int64(iTokenId))); err != nil {
+			log.Errorf("Token %s: scramble history: %v", id, err)
 		}
 	}
+
+	badges := EvaluateBadges(badgesConfig, decoded, history)
 	return &badges
 }
 
-func (g *Genome) Metadata(tokenId string, configService *config.ConfigService, badgesJsonMap *map[string][]string) Metadata {
-	var m Metadata
-	genes := g.genes()
-
-	revGenes := reverseGenesOrder(genes)
+// RecordBadges evaluates the badges of the token and records them, without building its metadata.
+func RecordBadges(stats BadgeStats, tokenId string, decoded DecodedGenome, badgesConfig *config.BadgeConfig) error {
+	return stats.Record(tokenId, *assignBadges(tokenId, decoded, badgesConfig))
+}
 
-	m.Attributes = g.attributes(configService)
-	m.Name = g.name(configService, tokenId)
-	m.Description = g.description(configService, tokenId)
-	m.ExternalUrl = fmt.Sprintf("%s%s", EXTERNAL_URL, tokenId)
-	m.Badges = assignBadges(tokenId, &revGenes, badgesJsonMap)
+// Metadata decodes the genome and builds the token metadata, rendering any missing images with the generator on the way.
+// When the generator has a queue, missing images are queued instead and the metadata points at the placeholders,
+// without animation, until the render is done. A failed upload of the iframe is logged and also leaves the animation
+// URL empty. When the generator publishes the renditions, image is an ipfs:// or ar:// URL, unless their upload fails,
+// which is logged.
+// The legacy fields are always set, see Metadata.Standard to drop them.
+// badgeStats is optional and adds the holders count to the badges.
+func (g *Genome) Metadata(ctx context.Context, tokenId string, configService *config.ConfigService, badgeStats BadgeStats, generator *Generator) (Metadata, error) {
+	var m Metadata
 
-	b := strings.Builder{}
-	t := strings.Builder{}
-	d := strings.Builder{}
+	decoded, err := g.Decode(configService.Slots)
+	if err != nil {
+		return m, err
+	}
 
-	polymorphImageUrlV1 := os.Getenv("POLYMORPH_IMAGE_URL_V1")
-	polymorphImageUrlV2 := os.Getenv("POLYMORPH_IMAGE_URL_V2")
+	genes := decoded.genes()
 
-	b.WriteString(polymorphImageUrlV1) // Start with base url
-	t.WriteString(polymorphImageUrlV2)
-	// d.WriteString(POLYMORPH_HTML_URL) // Start with HTML BaseURL
+	badges := assignBadges(tokenId, decoded, &configService.Badges)
+	m.BadgeDetails = describeBadges(&configService.Badges, tokenId, *badges, badgeStats)
 
-	for _, gene := range genes {
-		b.WriteString(gene)
-		t.WriteString(gene)
-		d.WriteString(gene)
+	attributes := decoded.attributes(configService)
+	badgesUrls := make([]string, 0, len(m.BadgeDetails))
+	for _, b := range m.BadgeDetails {
+		attributes = append(attributes, badgeAttribute(b))
+		badgesUrls = append(badgesUrls, b.Image)
 	}
+	attributes = append(attributes, getBadgeCountAttribute(len(m.BadgeDetails)))
 
-	b.WriteString(".jpg") // Finish with jpg extension
-	t.WriteString(".jpg")
-	// d.WriteString(".html")
+	m.Attributes = attributes
+	m.Badges = &badgesUrls
+	m.Name = decoded.name(configService, tokenId)
+	m.Description = decoded.description(configService, tokenId)
+	m.ExternalUrl = fmt.Sprintf("%s%s", EXTERNAL_URL, tokenId)
+	m.BackgroundColor = decoded.backgroundColor(configService)
 
-	image2DURL := b.String()
-	image3DURL := t.String()
-	animationURL := d.String()
+	renderKey := generator.RenderKey(genes)
+	animationURL := generator.iframeKey(genes)
 
-	image2DExists := imageExists(image2DURL)
-	image3DExists := imageExists(image3DURL)
+	urls2D, urls3D := generator.RenditionURLs(genes)
 
-	gcloudSourceV1BucketName := os.Getenv("GCLOUD_SOURCE_V1_BUCKET_NAME")
-	gcloudSourceV2BucketName := os.Getenv("GCLOUD_SOURCE_V2_BUCKET_NAME")
+	image2DURL, image3DURL := urls2D[RENDITION_FULL], urls3D[RENDITION_FULL]
 
-	gCloudUploadBucketName := os.Getenv("GCLOUD_UPLOAD_BUCKET_NAME")
-	gCloudUploadBucketName3D := os.Getenv("GCLOUD_UPLOAD_3D_BUCKET_NAME")
+	rendered, err := generator.imagesExist(ctx, genes)
+	if err != nil {
+		return m, err
+	}
 
-	if !image2DExists {
-		generateAndSaveImage(genes, gcloudSourceV1BucketName, gCloudUploadBucketName)
+	if !rendered && generator.Queue != nil {
+		job := generator.Queue.Enqueue(renderKey, genes)
+		m.RenderStatus = job.Status
+		m.Image2D = generator.Placeholder2D
+		m.Image3D = generator.Placeholder3D
+		m.Image = generator.Placeholder2D
+		return m, nil
 	}
-	if !image3DExists {
-		generateAndSaveImage(genes, gcloudSourceV2BucketName, gCloudUploadBucketName3D)
+
+	if !rendered {
+		if err := generator.renderImages(ctx, renderKey, genes, false); err != nil {
+			return m, err
+		}
 	}
 
+	m.RenderStatus = RENDER_STATUS_DONE
 	m.Image2D = image2DURL
 	m.Image3D = image3DURL
+	m.Image = image2DURL
+	m.ImageThumbnail = urls2D["thumbnail"]
+	m.ImageTransparent = urls2D["transparent"]
+	m.Renditions = map[string]map[string]string{"2d": urls2D, "3d": urls3D}
+
+	meta := ipfs.PinMetadata{TokenId: tokenId, Genome: string(*g)}
+	iframe2DURL, iframe3DURL := image2DURL, image3DURL
+
+	if generator.PublishRenditions && generator.Publishes() {
+		published, err := generator.publishImages(ctx, genes, meta)
+		if err != nil {
+			log.Errorf("Token %s: %v", tokenId, err)
+		} else {
+			gateway2D, gateway3D := generator.GatewayURLs(published, "2d"), generator.GatewayURLs(published, "3d")
+			m.Image = published["2d"][RENDITION_FULL]
+			m.ImageGateway = gateway2D[RENDITION_FULL]
+			m.RenditionURIs = published
+			iframe2DURL, iframe3DURL = gateway2D[RENDITION_FULL], gateway3D[RENDITION_FULL]
+		}
+	}
 
-	cid := generateAndSaveToIpfs(&animationURL, &image2DURL, &image3DURL, m.Badges)
-
-	m.AnimateUrl = "ipfs://" + cid
-	return m
+	uri, err := generator.generateAndPublishIframe(ctx, animationURL, iframe2DURL, iframe3DURL, m.BadgeDetails, meta)
+	if err != nil {
+		log.Errorf("Token %s: %v", tokenId, err)
+	} else {
+		m.AnimateUrl = uri
+	}
+	return m, nil
 }
 
+// Metadata follows the ERC-721 metadata JSON schema with the OpenSea extensions (image, animation_url, external_url,
+// background_color and attributes with display_type). The other fields are the legacy fields of the first clients,
+// which Standard removes.
 type Metadata struct {
-	Description string      `json:"description"`
-	Name        string      `json:"name"`
-	Image2D     string      `json:"image2D"`
-	Image3D     string      `json:"image3D"`
-	Badges      *[]string   `json:"badges_urls"`
-	AnimateUrl  string      `json:"animation_url"`
-	Attributes  interface{} `json:"attributes"`
-	ExternalUrl string      `json:"external_url"`
+	Description string `json:"description"`
+	Name        string `json:"name"`
+	Image2D     string `json:"image2D,omitempty"`
+	Image3D     string `json:"image3D,omitempty"`
+	// Image is the full 2D image, or its placeholder until rendered. ImageThumbnail and ImageTransparent are set once rendered
+	Image            string `json:"image"`
+	ImageThumbnail   string `json:"image_thumbnail,omitempty"`
+	ImageTransparent string `json:"image_transparent,omitempty"`
+	// ImageGateway is the gateway URL of Image when it's an ipfs:// or ar:// URL
+	ImageGateway string `json:"image_gateway,omitempty"`
+	// Renditions holds the URL of every rendition by name, under "2d" and "3d"
+	Renditions map[string]map[string]string `json:"renditions,omitempty"`
+	// RenditionURIs holds the ipfs:// or ar:// URL of every rendition, when the renditions are published
+	RenditionURIs PublishedImages `json:"rendition_uris,omitempty"`
+	Badges        *[]string       `json:"badges_urls,omitempty"`
+	BadgeDetails  []BadgeMetadata `json:"badges,omitempty"`
+	AnimateUrl    string          `json:"animation_url,omitempty"`
+	RenderStatus  string          `json:"render_status,omitempty"`
+	Attributes    interface{}     `json:"attributes"`
+	ExternalUrl   string          `json:"external_url"`
+	// BackgroundColor is the hex RGB color of the background, without "#", when config.json has background_colors
+	BackgroundColor string `json:"background_color,omitempty"`
+}
+
+// Standard returns the metadata with the ERC-721 and OpenSea fields only. The badges stay in the attributes and the
+// render status in the placeholder image.
+func (m Metadata) Standard() Metadata {
+	return Metadata{
+		Description:     m.Description,
+		Name:            m.Name,
+		Image:           m.Image,
+		AnimateUrl:      m.AnimateUrl,
+		Attributes:      m.Attributes,
+		ExternalUrl:     m.ExternalUrl,
+		BackgroundColor: m.BackgroundColor,
+	}
 }
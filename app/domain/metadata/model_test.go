@@ -0,0 +1,125 @@
+package metadata
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/polymorph-metadata/app/config"
+)
+
+func TestNameFromLayout(t *testing.T) {
+	// the character slot is not at the right end of the genome and reads another list than the default one
+	configService := &config.ConfigService{
+		Slots: config.GeneLayout{
+			{Name: "aura", Offset: 0, Width: 2, Count: 3, TraitType: "Aura", List: "aura", Layer: 0},
+			{Name: "character", Offset: 2, Width: 1, Count: 3, TraitType: "Hero", List: "heroes", Layer: 1},
+		},
+		Type: []string{"Elf", "Dwarf", "Orc"},
+		Lists: map[string][]string{
+			"aura":   {"None", "Glow", "Smoke"},
+			"heroes": {"Aria", "Borin", "Grok"},
+		},
+	}
+
+	tests := []struct {
+		genome      string
+		name        string
+		description string
+		attributes  string
+	}{
+		{"1102", "Borin #7", "The Dwarf named Borin #7", "Smoke Borin"},
+		{"1200", "Grok #7", "The Orc named Grok #7", "None Grok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.genome, func(t *testing.T) {
+			g := Genome(tt.genome)
+			decoded, err := g.Decode(configService.Slots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name := decoded.name(configService, "7"); name != tt.name {
+				t.Errorf("name = %q, want %q", name, tt.name)
+			}
+			if description := decoded.description(configService, "7"); !strings.HasPrefix(description, tt.description) {
+				t.Errorf("description = %q, want the prefix %q", description, tt.description)
+			}
+			var values []string
+			for _, a := range decoded.attributes(configService) {
+				values = append(values, a.(StringAttribute).Value)
+			}
+			if got := strings.Join(values, " "); got != tt.attributes {
+				t.Errorf("attributes = %q, want %q", got, tt.attributes)
+			}
+		})
+	}
+}
+
+func TestBackgroundColor(t *testing.T) {
+	configService := loadTestConfig(t)
+
+	tests := []struct {
+		genome string
+		color  string
+	}{
+		// background 00 and 11, the first and last colors of config.json
+		{"1070305021020300008", "e6e9f0"},
+		{"1070305021020301108", "96e6a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.genome, func(t *testing.T) {
+			g := Genome(tt.genome)
+			decoded, err := g.Decode(configService.Slots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if color := decoded.backgroundColor(configService); color != tt.color {
+				t.Errorf("backgroundColor = %q, want %q", color, tt.color)
+			}
+		})
+	}
+}
+
+func TestMetadataStandard(t *testing.T) {
+	badges := []string{"https://example.com/naked.svg"}
+	m := Metadata{
+		Description:      "The Wolf named Vitalik #1",
+		Name:             "Vitalik #1",
+		Image2D:          "https://example.com/2d.jpg",
+		Image3D:          "https://example.com/3d.jpg",
+		Image:            "https://example.com/2d.jpg",
+		ImageThumbnail:   "https://example.com/2d_thumbnail.jpg",
+		ImageTransparent: "https://example.com/2d_transparent.png",
+		ImageGateway:     "https://ipfs.io/ipfs/Qm",
+		Renditions:       map[string]map[string]string{"3d": {"full": "https://example.com/3d.jpg"}},
+		RenditionURIs:    PublishedImages{"2d": {"full": "ipfs://Qm"}},
+		Badges:           &badges,
+		BadgeDetails:     []BadgeMetadata{{Name: "naked"}},
+		AnimateUrl:       "ipfs://Qm",
+		RenderStatus:     RENDER_STATUS_DONE,
+		Attributes:       []interface{}{},
+		ExternalUrl:      "https://example.com/1",
+		BackgroundColor:  "e6e9f0",
+	}
+
+	b, err := json.Marshal(m.Standard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	want := "animation_url attributes background_color description external_url image name"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("Standard fields = %s, want %s", got, want)
+	}
+}
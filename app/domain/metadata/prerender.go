@@ -0,0 +1,38 @@
+package metadata
+
+import (
+	"context"
+
+	"github.com/polymorph-metadata/app/storage"
+)
+
+// MissingImages returns the keys of the renditions of the genome that are not rendered yet, under "2d" and "3d".
+func (gen *Generator) MissingImages(ctx context.Context, decoded DecodedGenome) (map[string][]string, error) {
+	genes := decoded.genes()
+
+	res := map[string][]string{}
+	for _, s := range []struct {
+		source string
+		v2     bool
+		images storage.ObjectStore
+	}{
+		{"2d", false, gen.Images2D},
+		{"3d", true, gen.Images3D},
+	} {
+		missing, err := gen.missingRenditions(ctx, s.v2, s.images, genes)
+		if err != nil {
+			return nil, err
+		}
+		genesKey := gen.renderKey(genes, s.v2)
+		for _, rendition := range missing {
+			res[s.source] = append(res[s.source], rendition.Key(genesKey))
+		}
+	}
+	return res, nil
+}
+
+// RenderImages renders the missing renditions of the genome within the call, or every rendition when force is set,
+// e.g. to warm the upload buckets before a launch.
+func (gen *Generator) RenderImages(ctx context.Context, decoded DecodedGenome, force bool) error {
+	return gen.renderImages(ctx, gen.RenderKey(decoded.genes()), decoded.genes(), force)
+}
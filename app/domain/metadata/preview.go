@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// ImageRequest describes a 2D image of a genome rendered on demand.
+type ImageRequest struct {
+	// Format is a key of ImageEncoders
+	Format string
+	// Size is the width and height in pixels, at most IMG_SIZE
+	Size int
+	// Transparent leaves out the background
+	Transparent bool
+	// Persist saves the image to the 2D upload store when it matches one of the renditions
+	Persist bool
+}
+
+// FormatOfExtension returns the format of a file extension, e.g. "jpeg" for "jpg".
+func FormatOfExtension(ext string) (string, error) {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	for format, encoder := range ImageEncoders {
+		if ext == format || ext == encoder.Extension {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, ext)
+}
+
+// rendition returns the rendition of the request, a configured one when they match.
+func (gen *Generator) rendition(req ImageRequest) (Rendition, bool) {
+	for _, r := range gen.renditions() {
+		if r.Format == req.Format && r.Size == req.Size && r.Transparent == req.Transparent {
+			return r, true
+		}
+	}
+	return Rendition{Name: "preview", Format: req.Format, Size: req.Size, Transparent: req.Transparent}, false
+}
+
+// ImageETag identifies the content of the image of the request, without rendering it. It changes with the art version.
+func (gen *Generator) ImageETag(decoded DecodedGenome, req ImageRequest) string {
+	rendition, _ := gen.rendition(req)
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%t", gen.renderKey(decoded.genes(), false), rendition.Format, rendition.Size, rendition.Quality, rendition.Transparent)))
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", sum[:16]))
+}
+
+// RenderImage returns the 2D image of the genome and its content type. Images matching a rendition are read
+// from the 2D upload store when they exist, and saved there when the request asks for it.
+func (gen *Generator) RenderImage(ctx context.Context, decoded DecodedGenome, req ImageRequest) ([]byte, string, error) {
+	encoder, ok := ImageEncoders[req.Format]
+	if !ok {
+		return nil, "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, req.Format)
+	}
+
+	genes := decoded.genes()
+	rendition, rendered := gen.rendition(req)
+	key := rendition.Key(gen.renderKey(genes, false))
+
+	if rendered {
+		exists, err := gen.Images2D.Exists(ctx, key)
+		if err != nil {
+			return nil, "", err
+		}
+		if exists {
+			r, err := gen.Images2D.Get(ctx, key)
+			if err != nil {
+				return nil, "", err
+			}
+			defer r.Close()
+			data, err := ioutil.ReadAll(r)
+			return data, encoder.ContentType, err
+		}
+	}
+
+	img, err := gen.composeGenes(ctx, genes, false, req.Transparent)
+	if err != nil {
+		return nil, "", err
+	}
+	defer releaseCanvas(img)
+
+	buf := &bytes.Buffer{}
+	if err := encodeRendition(buf, img, rendition); err != nil {
+		return nil, "", err
+	}
+
+	if rendered && req.Persist {
+		if err := gen.Images2D.Put(ctx, key, bytes.NewReader(buf.Bytes()), encoder.ContentType); err != nil {
+			return nil, "", fmt.Errorf("uploading %s: %w", key, err)
+		}
+	}
+
+	return buf.Bytes(), encoder.ContentType, nil
+}
@@ -0,0 +1,328 @@
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/polymorph-metadata/app/arweave"
+	"github.com/polymorph-metadata/app/ipfs"
+	"github.com/polymorph-metadata/app/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// DEFAULT_IPFS_GATEWAY_URL prefixes the CIDs of the HTTP URLs of the pinned images.
+const DEFAULT_IPFS_GATEWAY_URL = "https://ipfs.io/ipfs/"
+
+// URI schemes of the published content.
+const (
+	IPFS_SCHEME    = "ipfs://"
+	ARWEAVE_SCHEME = "ar://"
+)
+
+// ARWEAVE_INDEX_PREFIX prefixes the objects of the iframes store that hold the Arweave id of each upload and the
+// SHA-256 of its content, arweave/{key}.
+const ARWEAVE_INDEX_PREFIX = "arweave/"
+
+// ArweaveUploader uploads content to Arweave, see arweave.Uploader.
+type ArweaveUploader interface {
+	// Upload signs the data with its content type and the tags and returns its id
+	Upload(ctx context.Context, data []byte, contentType string, tags []arweave.Tag) (string, error)
+	// URL returns the gateway URL of the id
+	URL(id string) string
+}
+
+// PublishedImages holds the ipfs:// or ar:// URI of every rendition by name, under "2d" and "3d".
+type PublishedImages map[string]map[string]string
+
+// Publishes tells whether the generator publishes the iframes, to Arweave or IPFS.
+func (gen *Generator) Publishes() bool {
+	return gen.Arweave != nil || gen.Pinner != nil
+}
+
+// GatewayURL returns the HTTP URL of an ipfs:// or ar:// URI.
+func (gen *Generator) GatewayURL(uri string) string {
+	switch {
+	case strings.HasPrefix(uri, IPFS_SCHEME):
+		gateway := gen.IPFSGateway
+		if gateway == "" {
+			gateway = DEFAULT_IPFS_GATEWAY_URL
+		}
+		return strings.TrimSuffix(gateway, "/") + "/" + strings.TrimPrefix(uri, IPFS_SCHEME)
+	case strings.HasPrefix(uri, ARWEAVE_SCHEME) && gen.Arweave != nil:
+		return gen.Arweave.URL(strings.TrimPrefix(uri, ARWEAVE_SCHEME))
+	case strings.HasPrefix(uri, ARWEAVE_SCHEME):
+		return arweave.DEFAULT_GATEWAY_URL + "/" + strings.TrimPrefix(uri, ARWEAVE_SCHEME)
+	}
+	return uri
+}
+
+// GatewayURLs maps the URIs of a dimension of the images to their HTTP URLs.
+func (gen *Generator) GatewayURLs(images PublishedImages, dimension string) map[string]string {
+	res := make(map[string]string, len(images[dimension]))
+	for name, uri := range images[dimension] {
+		res[name] = gen.GatewayURL(uri)
+	}
+	return res
+}
+
+// PublishImages publishes every rendition of both images of the genome, which must be rendered, and returns their URIs.
+// Renders are immutable under their key, so the CID or id of each rendition is indexed under {2d,3d}/{object} and
+// renditions are only read and published once.
+func (gen *Generator) PublishImages(ctx context.Context, decoded DecodedGenome, tokenId string) (PublishedImages, error) {
+	genome, err := decoded.Genome()
+	if err != nil {
+		return nil, err
+	}
+	return gen.publishImages(ctx, decoded.genes(), ipfs.PinMetadata{TokenId: tokenId, Genome: genome.String()})
+}
+
+func (gen *Generator) publishImages(ctx context.Context, genes []string, meta ipfs.PinMetadata) (PublishedImages, error) {
+	if !gen.Publishes() {
+		return nil, errors.New("no IPFS pinner nor Arweave uploader")
+	}
+
+	res := PublishedImages{}
+	for _, s := range []struct {
+		dimension string
+		v2        bool
+		images    storage.ObjectStore
+	}{{"2d", false, gen.Images2D}, {"3d", true, gen.Images3D}} {
+		key := gen.renderKey(genes, s.v2)
+		res[s.dimension] = map[string]string{}
+
+		for _, rendition := range gen.renditions() {
+			object := rendition.Key(key)
+			indexKey := s.dimension + "/" + object
+			uri, err, _ := gen.flight.Do("publish/"+indexKey, func() (interface{}, error) {
+				return gen.publishImage(context.Background(), indexKey, s.images, object, meta)
+			})
+			if err != nil {
+				return nil, err
+			}
+			res[s.dimension][rendition.Name] = uri.(string)
+		}
+	}
+	return res, nil
+}
+
+func (gen *Generator) publishImage(ctx context.Context, indexKey string, images storage.ObjectStore, object string, meta ipfs.PinMetadata) (string, error) {
+	if gen.Arweave != nil {
+		if id := gen.arweaveIndexed(ctx, indexKey, ""); id != "" {
+			return ARWEAVE_SCHEME + id, nil
+		}
+	} else if indexed, err := gen.indexedCID(ctx, indexKey); err != nil {
+		log.Errorf("Reading the CID of %s: %v", indexKey, err)
+	} else if indexed != "" && ipfs.CIDVersion(indexed) == gen.CIDVersion {
+		// a CID of another version, indexed before IPFS_CID_VERSION changed, is computed and pinned again
+		return IPFS_SCHEME + indexed, nil
+	}
+
+	r, err := images.Get(ctx, object)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", object, err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", object, err)
+	}
+	meta.Name = path.Base(object)
+
+	if gen.Arweave != nil {
+		return gen.uploadToArweave(ctx, indexKey, content, imageContentType(object), meta)
+	}
+
+	cid, err := ipfs.ComputeCID(content, gen.CIDVersion)
+	if err != nil {
+		return "", err
+	}
+	cid, err = gen.pinContent(ctx, indexKey, cid, content, meta)
+	if err != nil {
+		return "", err
+	}
+	return IPFS_SCHEME + cid, nil
+}
+
+// PublishMetadata publishes the metadata JSON of the token and returns its URI. Unchanged metadata is not
+// published again.
+func (gen *Generator) PublishMetadata(ctx context.Context, tokenId string, m Metadata) (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	key := "metadata/" + tokenId
+	meta := ipfs.PinMetadata{Name: tokenId + ".json", TokenId: tokenId}
+
+	if gen.Arweave != nil {
+		sum := sha256.Sum256(data)
+		if id := gen.arweaveIndexed(ctx, key, hex.EncodeToString(sum[:])); id != "" {
+			return ARWEAVE_SCHEME + id, nil
+		}
+		return gen.uploadToArweave(ctx, key, data, "application/json", meta)
+	}
+	if gen.Pinner == nil {
+		return "", errors.New("no IPFS pinner nor Arweave uploader")
+	}
+
+	cid, err := ipfs.ComputeCID(data, gen.CIDVersion)
+	if err != nil {
+		return "", err
+	}
+	if indexed, err := gen.indexedCID(ctx, key); err == nil && indexed == cid {
+		return IPFS_SCHEME + cid, nil
+	}
+	cid, err = gen.pinContent(ctx, key, cid, data, meta)
+	if err != nil {
+		return "", err
+	}
+	return IPFS_SCHEME + cid, nil
+}
+
+// PublishMetadataManifest uploads an Arweave path manifest of the last published metadata of the tokens, so that
+// "ar://{manifest}/{tokenId}" resolves to the metadata of the token, and returns its URI. Tokens whose metadata
+// was never published are left out. IPFS has no such manifest.
+func (gen *Generator) PublishMetadataManifest(ctx context.Context, tokenIds []string) (string, int, error) {
+	if gen.Arweave == nil {
+		return "", 0, errors.New("path manifests are only published to Arweave")
+	}
+
+	ids := map[string]string{}
+	for _, tokenId := range tokenIds {
+		if id := gen.arweaveIndexed(ctx, "metadata/"+tokenId, ""); id != "" {
+			ids[tokenId] = id
+		}
+	}
+
+	data, err := json.Marshal(arweave.NewManifest(ids))
+	if err != nil {
+		return "", 0, err
+	}
+	id, err := gen.Arweave.Upload(ctx, data, arweave.MANIFEST_CONTENT_TYPE, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	return ARWEAVE_SCHEME + id, len(ids), nil
+}
+
+// pinContent pins the content unless its CID is already pinned and indexes the CID under the key.
+func (gen *Generator) pinContent(ctx context.Context, key string, cid string, content []byte, meta ipfs.PinMetadata) (string, error) {
+	pinned, err := gen.Pinner.IsPinned(ctx, cid)
+	if err != nil {
+		return "", fmt.Errorf("checking the pin of %s: %w", key, err)
+	}
+	if !pinned {
+		res, err := gen.Pinner.Pin(ctx, content, meta)
+		if err != nil {
+			return "", fmt.Errorf("pinning %s: %w", key, err)
+		}
+		if res != cid {
+			log.Warnf("%s was pinned as %s instead of the computed %s, check IPFS_CID_VERSION", key, res, cid)
+			cid = res
+		}
+	}
+
+	if err := gen.indexCID(ctx, key, cid); err != nil {
+		log.Errorf("Indexing the CID of %s: %v", key, err)
+	}
+	return cid, nil
+}
+
+// uploadToArweave uploads the content, tagged with the token id and the genome, and indexes its id under the key.
+func (gen *Generator) uploadToArweave(ctx context.Context, key string, content []byte, contentType string, meta ipfs.PinMetadata) (string, error) {
+	var tags []arweave.Tag
+	if meta.TokenId != "" {
+		tags = append(tags, arweave.Tag{Name: "Token-Id", Value: meta.TokenId})
+	}
+	if meta.Genome != "" {
+		tags = append(tags, arweave.Tag{Name: "Genome", Value: meta.Genome})
+	}
+
+	id, err := gen.Arweave.Upload(ctx, content, contentType, tags)
+	if err != nil {
+		return "", fmt.Errorf("uploading %s: %w", key, err)
+	}
+
+	sum := sha256.Sum256(content)
+	entry := id + " " + hex.EncodeToString(sum[:])
+	if err := gen.Iframes.Put(ctx, ARWEAVE_INDEX_PREFIX+key, strings.NewReader(entry), "text/plain"); err != nil {
+		log.Errorf("Indexing the Arweave id of %s: %v", key, err)
+	}
+	return ARWEAVE_SCHEME + id, nil
+}
+
+// arweaveIndexed returns the id of the last upload under the key, when its content has the SHA-256 sum or for
+// any content when sum is empty, an empty string otherwise. A failing index is logged.
+func (gen *Generator) arweaveIndexed(ctx context.Context, key string, sum string) string {
+	r, err := gen.Iframes.Get(ctx, ARWEAVE_INDEX_PREFIX+key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return ""
+	} else if err != nil {
+		log.Errorf("Reading the Arweave id of %s: %v", key, err)
+		return ""
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		log.Errorf("Reading the Arweave id of %s: %v", key, err)
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 || (sum != "" && fields[1] != sum) {
+		return ""
+	}
+	return fields[0]
+}
+
+// imageContentType returns the content type of a rendition object.
+func imageContentType(object string) string {
+	if strings.HasSuffix(object, ".png") {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
+
+// indexedCID returns the CID last pinned under the key, an empty string when nothing was pinned.
+func (gen *Generator) indexedCID(ctx context.Context, key string) (string, error) {
+	if gen.cids != nil {
+		if cid, ok := gen.cids.Get(key); ok {
+			return cid.(string), nil
+		}
+	}
+
+	r, err := gen.Iframes.Get(ctx, CID_INDEX_PREFIX+key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	cid := strings.TrimSpace(string(data))
+	if gen.cids != nil {
+		gen.cids.Add(key, cid)
+	}
+	return cid, nil
+}
+
+func (gen *Generator) indexCID(ctx context.Context, key string, cid string) error {
+	if err := gen.Iframes.Put(ctx, CID_INDEX_PREFIX+key, strings.NewReader(cid), "text/plain"); err != nil {
+		return err
+	}
+	if gen.cids != nil {
+		gen.cids.Add(key, cid)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/polymorph-metadata/app/ipfs"
+)
+
+func TestPublishImageCIDVersion(t *testing.T) {
+	content := []byte("jpeg")
+	v0, _ := ipfs.ComputeCID(content, 0)
+	v1, _ := ipfs.ComputeCID(content, 1)
+
+	tests := []struct {
+		name       string
+		cidVersion int
+		cid        string
+		pins       int
+	}{
+		{"indexed version", 0, v0, 0},
+		{"other version", 1, v1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			images := &memoryLayerStore{layers: map[string][]byte{"1070305021020301108.jpg": content}}
+			// the index of the CIDv0 of the image
+			iframes := &memoryLayerStore{layers: map[string][]byte{CID_INDEX_PREFIX + "2d/1070305021020301108.jpg": []byte(v0)}}
+			pinner := ipfs.NewMock()
+			pinner.CIDVersion = tt.cidVersion
+			gen := &Generator{Iframes: iframes, Pinner: pinner, CIDVersion: tt.cidVersion}
+
+			uri, err := gen.publishImage(context.Background(), "2d/1070305021020301108.jpg", images, "1070305021020301108.jpg", ipfs.PinMetadata{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uri != IPFS_SCHEME+tt.cid {
+				t.Errorf("publishImage = %s, want %s", uri, IPFS_SCHEME+tt.cid)
+			}
+			if pinner.Calls != tt.pins {
+				t.Errorf("%d pins, want %d", pinner.Calls, tt.pins)
+			}
+
+			r, err := iframes.Get(context.Background(), CID_INDEX_PREFIX+"2d/1070305021020301108.jpg")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if indexed, _ := ioutil.ReadAll(r); string(indexed) != tt.cid {
+				t.Errorf("indexed CID = %s, want %s", indexed, tt.cid)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package metadata
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Render statuses of a genome, see Metadata.RenderStatus.
+const (
+	RENDER_STATUS_QUEUED    = "queued"
+	RENDER_STATUS_RENDERING = "rendering"
+	RENDER_STATUS_DONE      = "done"
+	RENDER_STATUS_FAILED    = "failed"
+	// RENDER_STATUS_MISSING is the status of genomes that were never rendered nor queued
+	RENDER_STATUS_MISSING = "missing"
+)
+
+// RENDER_JOB_HEARTBEAT is how often the queue updates its pending jobs in the store, to show they are alive.
+const RENDER_JOB_HEARTBEAT = 30 * time.Second
+
+// RENDER_JOB_STALE_AFTER is how long a pending job goes without update before another instance takes it over.
+const RENDER_JOB_STALE_AFTER = 4 * RENDER_JOB_HEARTBEAT
+
+// RenderJob is the render of the images of a genome.
+type RenderJob struct {
+	// Key is the render key of the genome, see Generator.RenderKey. Genomes with the same genes and art share their job
+	Key       string    `json:"key"`
+	Genes     []string  `json:"-"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RenderJobStore persists the render jobs, so that they survive a restart and are visible to every instance.
+type RenderJobStore interface {
+	Save(job RenderJob) error
+	// Get returns nil when the job is unknown
+	Get(key string) (*RenderJob, error)
+	// Pending returns the jobs that are queued or rendering
+	Pending() ([]RenderJob, error)
+	// Claim moves the UpdatedAt of the job to at and returns true, unless the job was updated since it was read
+	Claim(job RenderJob, at time.Time) (bool, error)
+}
+
+// RenderQueue renders the missing images of genomes on a fixed number of workers, one job per genome.
+type RenderQueue struct {
+	generator *Generator
+	store     RenderJobStore
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    map[string]*RenderJob
+	pending []string
+}
+
+// NewRenderQueue starts the workers. store is optional, the pending jobs it holds are resumed once stale, i.e.
+// when the instance that queued them stopped updating them, and claimed so that a single instance resumes each.
+func NewRenderQueue(generator *Generator, workers int, store RenderJobStore) *RenderQueue {
+	q := &RenderQueue{generator: generator, store: store, jobs: map[string]*RenderJob{}}
+	q.cond = sync.NewCond(&q.mu)
+
+	if store != nil {
+		q.resumeStale()
+		go q.heartbeat()
+	}
+
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// resumeStale queues the pending jobs of the store that no live instance updated for RENDER_JOB_STALE_AFTER.
+func (q *RenderQueue) resumeStale() {
+	pending, err := q.store.Pending()
+	if err != nil {
+		log.Errorf("Resuming render jobs: %v", err)
+	}
+
+	resumed := 0
+	for _, job := range pending {
+		if time.Since(job.UpdatedAt) < RENDER_JOB_STALE_AFTER {
+			continue
+		}
+		claimed, err := q.store.Claim(job, time.Now())
+		if err != nil {
+			log.Errorf("Claiming render job %s: %v", job.Key, err)
+			continue
+		}
+		if claimed {
+			q.Enqueue(job.Key, job.Genes)
+			resumed++
+		}
+	}
+	if resumed > 0 {
+		log.Infof("Resumed %d stale render job(s)", resumed)
+	}
+}
+
+// heartbeat updates the pending jobs of the queue in the store, so that other instances don't resume them.
+func (q *RenderQueue) heartbeat() {
+	for range time.Tick(RENDER_JOB_HEARTBEAT) {
+		q.mu.Lock()
+		var alive []RenderJob
+		for _, job := range q.jobs {
+			if job.Status == RENDER_STATUS_QUEUED || job.Status == RENDER_STATUS_RENDERING {
+				job.UpdatedAt = time.Now()
+				alive = append(alive, *job)
+			}
+		}
+		q.mu.Unlock()
+
+		for _, job := range alive {
+			q.save(job)
+		}
+	}
+}
+
+// Enqueue queues the render of the genes unless it is already queued or rendering, and returns its job.
+// Failed jobs are queued again.
+func (q *RenderQueue) Enqueue(key string, genes []string) RenderJob {
+	q.mu.Lock()
+	if job, ok := q.jobs[key]; ok && job.Status != RENDER_STATUS_FAILED {
+		q.mu.Unlock()
+		return *job
+	}
+
+	job := RenderJob{Key: key, Genes: genes, Status: RENDER_STATUS_QUEUED, UpdatedAt: time.Now()}
+	q.jobs[key] = &job
+	q.pending = append(q.pending, key)
+	q.cond.Signal()
+	q.mu.Unlock()
+
+	q.save(job)
+	return job
+}
+
+// Status returns the job of the render key, looking into the store for jobs of other instances.
+// Done jobs are forgotten, as their images exist.
+func (q *RenderQueue) Status(key string) (RenderJob, bool) {
+	q.mu.Lock()
+	job, ok := q.jobs[key]
+	q.mu.Unlock()
+
+	if ok {
+		return *job, true
+	}
+
+	if q.store != nil {
+		job, err := q.store.Get(key)
+		if err != nil {
+			log.Errorf("Render job %s: %v", key, err)
+		}
+		if job != nil {
+			return *job, true
+		}
+	}
+	return RenderJob{}, false
+}
+
+func (q *RenderQueue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.pending) == 0 {
+			q.cond.Wait()
+		}
+		key := q.pending[0]
+		q.pending = q.pending[1:]
+		job := q.jobs[key]
+		job.Status = RENDER_STATUS_RENDERING
+		job.UpdatedAt = time.Now()
+		rendering := *job
+		q.mu.Unlock()
+
+		q.save(rendering)
+		err := q.generator.renderImages(context.Background(), key, rendering.Genes, false)
+
+		q.mu.Lock()
+		job.UpdatedAt = time.Now()
+		if err != nil {
+			log.Errorf("Rendering %s: %v", key, err)
+			job.Status = RENDER_STATUS_FAILED
+			job.Error = err.Error()
+		} else {
+			job.Status = RENDER_STATUS_DONE
+			job.Error = ""
+			delete(q.jobs, key)
+		}
+		done := *job
+		q.mu.Unlock()
+
+		q.save(done)
+	}
+}
+
+func (q *RenderQueue) save(job RenderJob) {
+	if q.store == nil {
+		return
+	}
+	if err := q.store.Save(job); err != nil {
+		log.Errorf("Saving render job %s: %v", job.Key, err)
+	}
+}
+
+// RenderStatus returns the job of the genome, or a done or missing job depending on whether its images exist.
+func (gen *Generator) RenderStatus(ctx context.Context, decoded DecodedGenome) (RenderJob, error) {
+	key := gen.RenderKey(decoded.genes())
+
+	if gen.Queue != nil {
+		if job, ok := gen.Queue.Status(key); ok {
+			return job, nil
+		}
+	}
+
+	rendered, err := gen.imagesExist(ctx, decoded.genes())
+	if err != nil {
+		return RenderJob{}, err
+	}
+	if rendered {
+		return RenderJob{Key: key, Status: RENDER_STATUS_DONE}, nil
+	}
+	return RenderJob{Key: key, Status: RENDER_STATUS_MISSING}, nil
+}
@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryJobStore is a RenderJobStore shared by the queues of a test, like the collection of several instances.
+type memoryJobStore struct {
+	mu   sync.Mutex
+	jobs map[string]RenderJob
+}
+
+func (s *memoryJobStore) Save(job RenderJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Key] = job
+	return nil
+}
+
+func (s *memoryJobStore) Get(key string) (*RenderJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[key]; ok {
+		return &job, nil
+	}
+	return nil, nil
+}
+
+func (s *memoryJobStore) Pending() ([]RenderJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []RenderJob
+	for _, job := range s.jobs {
+		if job.Status == RENDER_STATUS_QUEUED || job.Status == RENDER_STATUS_RENDERING {
+			res = append(res, job)
+		}
+	}
+	return res, nil
+}
+
+func (s *memoryJobStore) Claim(job RenderJob, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.jobs[job.Key]
+	if !ok || !current.UpdatedAt.Equal(job.UpdatedAt) {
+		return false, nil
+	}
+	current.UpdatedAt = at
+	s.jobs[job.Key] = current
+	return true, nil
+}
+
+func TestRenderQueueResumesStaleJobsOnce(t *testing.T) {
+	stale := time.Now().Add(-2 * RENDER_JOB_STALE_AFTER)
+	store := &memoryJobStore{jobs: map[string]RenderJob{
+		"live":     {Key: "live", Status: RENDER_STATUS_RENDERING, UpdatedAt: time.Now()},
+		"queued":   {Key: "queued", Status: RENDER_STATUS_QUEUED, UpdatedAt: stale},
+		"crashed":  {Key: "crashed", Status: RENDER_STATUS_RENDERING, UpdatedAt: stale},
+		"finished": {Key: "finished", Status: RENDER_STATUS_DONE, UpdatedAt: stale},
+	}}
+
+	// no workers, so that the resumed jobs stay queued
+	first := NewRenderQueue(&Generator{}, 0, store)
+	second := NewRenderQueue(&Generator{}, 0, store)
+
+	tests := []struct {
+		key    string
+		first  bool
+		second bool
+	}{
+		{"live", false, false},
+		{"queued", true, false},
+		{"crashed", true, false},
+		{"finished", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, ok := first.jobs[tt.key]; ok != tt.first {
+				t.Errorf("resumed by the first instance = %t, want %t", ok, tt.first)
+			}
+			if _, ok := second.jobs[tt.key]; ok != tt.second {
+				t.Errorf("resumed by the second instance = %t, want %t", ok, tt.second)
+			}
+		})
+	}
+}
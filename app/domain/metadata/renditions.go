@@ -0,0 +1,107 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	log "github.com/sirupsen/logrus"
+)
+
+const DEFAULT_JPEG_QUALITY = 80
+
+// RENDITION_FULL is the name of the rendition stored under the legacy key, e.g. 0102...08.jpg.
+const RENDITION_FULL = "full"
+
+// Rendition is one of the images rendered for every genome.
+type Rendition struct {
+	Name string `json:"name"`
+	// Size is the width and height in pixels, at most IMG_SIZE
+	Size int `json:"size"`
+	// Format is a key of ImageEncoders
+	Format string `json:"format"`
+	// Quality of lossy formats, from 1 to 100
+	Quality int `json:"quality,omitempty"`
+	// Transparent leaves out the bottom layer, the background
+	Transparent bool `json:"transparent,omitempty"`
+}
+
+// DEFAULT_RENDITIONS are rendered when RENDITIONS is not set.
+var DEFAULT_RENDITIONS = []Rendition{
+	{Name: RENDITION_FULL, Size: IMG_SIZE, Format: "jpeg", Quality: DEFAULT_JPEG_QUALITY},
+	{Name: "medium", Size: 1024, Format: "jpeg", Quality: DEFAULT_JPEG_QUALITY},
+	{Name: "thumbnail", Size: 256, Format: "jpeg", Quality: DEFAULT_JPEG_QUALITY},
+	{Name: "transparent", Size: IMG_SIZE, Format: "png", Transparent: true},
+}
+
+// ImageEncoder writes an image in a given format.
+type ImageEncoder struct {
+	Extension   string
+	ContentType string
+	Encode      func(w io.Writer, img image.Image, quality int) error
+}
+
+// ImageEncoders are the available rendition formats. There is no WebP nor AVIF encoder in the standard library,
+// renditions in these formats are skipped until an encoder is registered here.
+var ImageEncoders = map[string]ImageEncoder{
+	"jpeg": {Extension: "jpg", ContentType: "image/jpeg", Encode: func(w io.Writer, img image.Image, quality int) error {
+		if quality <= 0 {
+			quality = DEFAULT_JPEG_QUALITY
+		}
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(quality))
+	}},
+	"png": {Extension: "png", ContentType: "image/png", Encode: func(w io.Writer, img image.Image, quality int) error {
+		return imaging.Encode(w, img, imaging.PNG)
+	}},
+}
+
+// Key returns the object name of the rendition of the genes, e.g. 0102...08_thumbnail.jpg.
+func (r Rendition) Key(genesKey string) string {
+	if r.Name == RENDITION_FULL {
+		return genesKey + "." + ImageEncoders[r.Format].Extension
+	}
+	return genesKey + "_" + r.Name + "." + ImageEncoders[r.Format].Extension
+}
+
+// ParseRenditions reads a JSON array of renditions, which must include the full one.
+// Renditions in a format without encoder are logged and left out.
+func ParseRenditions(data string) ([]Rendition, error) {
+	var renditions []Rendition
+	if err := json.Unmarshal([]byte(data), &renditions); err != nil {
+		return nil, fmt.Errorf("renditions: %w", err)
+	}
+
+	var res []Rendition
+	names := map[string]bool{}
+	for _, r := range renditions {
+		r.Format = strings.ToLower(r.Format)
+		switch {
+		case r.Name == "" || strings.ContainsAny(r.Name, "/_."):
+			return nil, fmt.Errorf("renditions: invalid name %q", r.Name)
+		case names[r.Name]:
+			return nil, fmt.Errorf("renditions: %q is declared twice", r.Name)
+		case r.Size <= 0 || r.Size > IMG_SIZE:
+			return nil, fmt.Errorf("renditions: %q: size must be between 1 and %d", r.Name, IMG_SIZE)
+		}
+		names[r.Name] = true
+
+		if _, ok := ImageEncoders[r.Format]; !ok {
+			log.Warnf("Rendition %q skipped: no encoder for %q in this build", r.Name, r.Format)
+			continue
+		}
+		res = append(res, r)
+	}
+
+	if !names[RENDITION_FULL] {
+		return nil, fmt.Errorf("renditions: the %q rendition is required, it is the image2D and image3D of the metadata", RENDITION_FULL)
+	}
+	for _, r := range res {
+		if r.Name == RENDITION_FULL {
+			return res, nil
+		}
+	}
+	return nil, fmt.Errorf("renditions: the %q rendition can't be encoded", RENDITION_FULL)
+}
@@ -0,0 +1,132 @@
+package metadata
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/polymorph-metadata/app/config"
+)
+
+// UnknownTraitError is returned when a trait matches no variant of any slot.
+type UnknownTraitError struct {
+	Trait string
+}
+
+func (e *UnknownTraitError) Error() string {
+	return fmt.Sprintf("unknown trait %q", e.Trait)
+}
+
+// AmbiguousTraitError is returned when a trait name exists in several slots and has to be qualified.
+type AmbiguousTraitError struct {
+	Trait string
+	Slots []string
+}
+
+func (e *AmbiguousTraitError) Error() string {
+	return fmt.Sprintf("trait %q exists in %s, prefix it with one of them, e.g. %q", e.Trait, strings.Join(e.Slots, ", "), e.Slots[0]+":"+e.Trait)
+}
+
+// DuplicateSlotError is returned when two traits set the same slot.
+type DuplicateSlotError struct {
+	Slot   string
+	Traits []string
+}
+
+func (e *DuplicateSlotError) Error() string {
+	return fmt.Sprintf("traits %q and %q both set the %s slot", e.Traits[0], e.Traits[1], e.Slot)
+}
+
+// GenomeFromTraits builds the genome that carries the given traits. A trait is either a trait name
+// ("Astronaut Footwear", "Vitalik") or a slot qualified name or variant index ("left hand:Katana", "headwear:3").
+// Slots that are not mentioned keep variant 0, a slot set by two traits is an error.
+func GenomeFromTraits(configService *config.ConfigService, traits []string) (DecodedGenome, error) {
+	d := DecodedGenome{layout: configService.Slots}
+	slots := d.Layout()
+
+	setBy := map[string]string{}
+	setGene := func(slot string, gene Gene, trait string) error {
+		if previous, ok := setBy[slot]; ok {
+			return &DuplicateSlotError{Slot: slot, Traits: []string{previous, trait}}
+		}
+		setBy[slot] = trait
+		d.SetGene(slot, gene)
+		return nil
+	}
+
+	for _, trait := range traits {
+		trait = strings.TrimSpace(trait)
+
+		if i := strings.Index(trait, ":"); i >= 0 {
+			slot := findSlot(slots, trait[:i])
+			if slot == nil {
+				return DecodedGenome{}, &UnknownTraitError{Trait: trait}
+			}
+			gene, err := resolveTrait(configService, slot, strings.TrimSpace(trait[i+1:]))
+			if err != nil {
+				return DecodedGenome{}, err
+			}
+			if err := setGene(slot.Name, gene, trait); err != nil {
+				return DecodedGenome{}, err
+			}
+			continue
+		}
+
+		var matches []string
+		var gene Gene
+		for i := range slots {
+			if g, err := resolveTraitName(configService, &slots[i], trait); err == nil {
+				matches = append(matches, slots[i].Name)
+				gene = g
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return DecodedGenome{}, &UnknownTraitError{Trait: trait}
+		case 1:
+			if err := setGene(matches[0], gene, trait); err != nil {
+				return DecodedGenome{}, err
+			}
+		default:
+			return DecodedGenome{}, &AmbiguousTraitError{Trait: trait, Slots: matches}
+		}
+	}
+
+	return d, nil
+}
+
+// findSlot matches the slot name or its trait type case-insensitively, so "left hand", "Left Hand" and "lefthand" all work.
+func findSlot(slots config.GeneLayout, name string) *config.GeneSlot {
+	name = normalizeSlotName(name)
+	for i := range slots {
+		if normalizeSlotName(slots[i].Name) == name || normalizeSlotName(slots[i].TraitType) == name {
+			return &slots[i]
+		}
+	}
+	return nil
+}
+
+func normalizeSlotName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "")
+}
+
+func resolveTrait(configService *config.ConfigService, slot *config.GeneSlot, value string) (Gene, error) {
+	if index, err := strconv.Atoi(value); err == nil {
+		if index < 0 || index >= slot.Count {
+			return 0, &GeneOutOfRangeError{Slot: slot.Name, Value: index, Count: slot.Count}
+		}
+		return Gene(index), nil
+	}
+	return resolveTraitName(configService, slot, value)
+}
+
+func resolveTraitName(configService *config.ConfigService, slot *config.GeneSlot, name string) (Gene, error) {
+	traits := configService.TraitList(slot.List)
+	for i := 0; i < len(traits) && i < slot.Count; i++ {
+		if strings.EqualFold(traits[i], name) {
+			return Gene(i), nil
+		}
+	}
+	return 0, &UnknownTraitError{Trait: slot.Name + ":" + name}
+}
@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/assets"
+)
+
+func loadTestConfig(t *testing.T) *config.ConfigService {
+	t.Helper()
+	configService, err := config.LoadConfigServices(assets.Files(), assets.CONFIG_FILE, assets.BADGES_FILE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return configService
+}
+
+func TestGenomeFromTraits(t *testing.T) {
+	configService := loadTestConfig(t)
+
+	tests := []struct {
+		name   string
+		traits []string
+		genes  map[string]Gene
+		err    interface{}
+	}{
+		{name: "names", traits: []string{"Vitalik", "Astronaut Footwear"}, genes: map[string]Gene{"character": 8, "footwear": 2}},
+		{name: "qualified name", traits: []string{"left hand:Katana", "Right Hand:3"}, genes: map[string]Gene{"left hand": 23, "right hand": 3}},
+		{name: "case and spaces", traits: []string{" vitalik ", "lefthand:katana"}, genes: map[string]Gene{"character": 8, "left hand": 23}},
+		{name: "no traits", genes: map[string]Gene{"character": 0, "headwear": 0}},
+		{name: "ambiguous", traits: []string{"Katana"}, err: new(*AmbiguousTraitError)},
+		{name: "unknown trait", traits: []string{"Jetpack"}, err: new(*UnknownTraitError)},
+		{name: "unknown slot", traits: []string{"tail:1"}, err: new(*UnknownTraitError)},
+		{name: "out of range", traits: []string{"headwear:31"}, err: new(*GeneOutOfRangeError)},
+		{name: "same slot twice", traits: []string{"left hand:Katana", "left hand:3"}, err: new(*DuplicateSlotError)},
+		{name: "same character twice", traits: []string{"Vitalik", "character:Escrow"}, err: new(*DuplicateSlotError)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := GenomeFromTraits(configService, tt.traits)
+			if tt.err != nil {
+				if !errors.As(err, tt.err) {
+					t.Fatalf("error = %v, want a %T", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			genome, err := decoded.Genome()
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := Genome(genome.String())
+			roundTrip, err := g.Decode(configService.Slots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for slot, want := range tt.genes {
+				if got := roundTrip.Gene(slot); got != want {
+					t.Errorf("%s = %d, want %d", slot, got, want)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi"
@@ -24,9 +25,28 @@ type APIResponse struct {
 }
 
 func (api *API) AddRouter(route string, router APIRoute) {
+	api.r.With(middleware.NoCache).Mount(route, router)
+}
+
+// AddCacheableRouter mounts a router whose responses set their own cache headers, e.g. images.
+func (api *API) AddCacheableRouter(route string, router APIRoute) {
 	api.r.Mount(route, router)
 }
 
+// AddRoute serves the handler at the path, and under it when the path ends with "/". Unless the route is cacheable,
+// its responses are not cached.
+func (api *API) AddRoute(path string, handler http.HandlerFunc, cacheable bool) {
+	pattern := path
+	if strings.HasSuffix(path, "/") {
+		pattern += "*"
+	}
+	if cacheable {
+		api.r.HandleFunc(pattern, handler)
+		return
+	}
+	api.r.With(middleware.NoCache).HandleFunc(pattern, handler)
+}
+
 func (api *API) Start(port string) error {
 	log.Infof("[API] Listening for REST API Requests on port %v\n", port)
 	return http.ListenAndServe(fmt.Sprintf(":%s", port), api.r)
@@ -51,7 +71,6 @@ func NewAPI() *API {
 		middleware.Compress(6, "gzip"),
 		middleware.RedirectSlashes,
 		middleware.Recoverer,
-		middleware.NoCache,
 		middleware.Timeout(60*time.Second),
 		c.Handler,
 	)
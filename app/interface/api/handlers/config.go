@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/polymorph-metadata/app/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// CONFIG_VERSION_HEADER carries the version of the config that served the response.
+const CONFIG_VERSION_HEADER = "X-Config-Version"
+
+type configReloadResponse struct {
+	Version string `json:"version"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HandleConfigReloadRequest reloads the trait and badge config. The request must carry the ADMIN_TOKEN
+// env variable as a bearer token, the endpoint is disabled when ADMIN_TOKEN is empty.
+func HandleConfigReloadRequest(configHolder *config.Holder) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminToken := os.Getenv("ADMIN_TOKEN")
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			render.Status(r, 403)
+			render.JSON(w, r, "Forbidden")
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			render.Status(r, 405)
+			render.JSON(w, r, "Method not allowed")
+			return
+		}
+
+		configService, err := configHolder.Reload()
+		w.Header().Set(CONFIG_VERSION_HEADER, configService.Version)
+		if err != nil {
+			render.Status(r, 422)
+			render.JSON(w, r, configReloadResponse{Version: configService.Version, Error: err.Error()})
+			log.Errorln(err)
+			return
+		}
+
+		render.JSON(w, r, configReloadResponse{Version: configService.Version})
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	log "github.com/sirupsen/logrus"
+)
+
+type genomeResponse struct {
+	Genome   string `json:"genome"`
+	ImageKey string `json:"image_key"`
+}
+
+// HandleGenomeRequest builds the genome of the traits passed as repeated trait query params,
+// e.g. /genome?trait=Vitalik&trait=Astronaut%20Footwear&trait=left%20hand:Katana, along with the key of its
+// full 2D image at the current art version.
+func HandleGenomeRequest(configHolder *config.Holder, generator *metadata.Generator) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		configService := configHolder.Current()
+		w.Header().Set(CONFIG_VERSION_HEADER, configService.Version)
+
+		decoded, err := metadata.GenomeFromTraits(configService, r.URL.Query()["trait"])
+		if err != nil {
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		}
+
+		genome, err := decoded.Genome()
+		if err != nil {
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		}
+
+		render.JSON(w, r, genomeResponse{Genome: genome.String(), ImageKey: generator.ImageKey(decoded)})
+	}
+}
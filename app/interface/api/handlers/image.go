@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// IMAGE_PATH is the path of the images, followed by the genome and the extension, e.g. /image/1080...08.png
+const IMAGE_PATH = "/image/"
+
+// IMAGE_CACHE_CONTROL lets clients and CDNs keep images for an hour. The URL has no art version, so the same URL
+// serves new bytes after a layer change and clients revalidate with the ETag, which changes with the art version.
+const IMAGE_CACHE_CONTROL = "public, max-age=3600"
+
+// HandleImageRequest renders the 2D image of GET /image/{genome}.{ext}?size=&bg=, where ext is jpg or png,
+// size is the width in pixels, up to metadata.IMG_SIZE, and bg=false or bg=none leaves out the background.
+// Images that match a rendition are saved to the upload bucket when persist is set.
+func HandleImageRequest(configHolder *config.Holder, generator *metadata.Generator, persist bool) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		configService := configHolder.Current()
+
+		file := path.Base(r.URL.Path)
+		ext := path.Ext(file)
+
+		format, err := metadata.FormatOfExtension(ext)
+		if err != nil {
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
+			return
+		}
+
+		req, err := parseImageRequest(r)
+		if err != nil {
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
+			return
+		}
+		req.Format = format
+		req.Persist = persist
+
+		genome := metadata.Genome(strings.TrimSuffix(file, ext))
+		decoded, err := genome.Decode(configService.Slots)
+		if err != nil {
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
+			return
+		}
+
+		etag := generator.ImageETag(decoded, req)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", IMAGE_CACHE_CONTROL)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		data, contentType, err := generator.RenderImage(r.Context(), decoded, req)
+		if errors.Is(err, storage.ErrNotFound) {
+			render.Status(r, 404)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		} else if err != nil {
+			render.Status(r, 500)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", IMAGE_CACHE_CONTROL)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}
+}
+
+// etagMatches compares the ETag with an If-None-Match header, a comma-separated list of ETags or "*", using the
+// weak comparison of RFC 7232.
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseImageRequest(r *http.Request) (metadata.ImageRequest, error) {
+	req := metadata.ImageRequest{Size: metadata.IMG_SIZE}
+
+	if s := r.URL.Query().Get("size"); s != "" {
+		size, err := strconv.Atoi(s)
+		if err != nil || size < 1 || size > metadata.IMG_SIZE {
+			return req, errors.New("size must be between 1 and " + strconv.Itoa(metadata.IMG_SIZE))
+		}
+		req.Size = size
+	}
+
+	if bg := r.URL.Query().Get("bg"); bg != "" {
+		if bg == "none" {
+			req.Transparent = true
+		} else if show, err := strconv.ParseBool(bg); err == nil {
+			req.Transparent = !show
+		} else {
+			return req, errors.New("bg must be true, false or none")
+		}
+	}
+
+	return req, nil
+}
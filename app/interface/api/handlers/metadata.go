@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"net/http"
 	"os"
@@ -8,75 +11,173 @@ import (
 
 	"github.com/polymorph-metadata/app/interface/dlt/ethereum"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-chi/render"
+	"github.com/polymorph-metadata/app/arweave"
 	"github.com/polymorph-metadata/app/config"
-	"github.com/polymorph-metadata/app/contracts"
 	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/storage"
+	"github.com/polymorph-metadata/db"
 	log "github.com/sirupsen/logrus"
 )
 
-func HandleMetadataRequest(ethClient *ethereumclient.EthereumClient, polygonClient *ethereumclient.EthereumClient, address string, addressPolygon string, configService *config.ConfigService, badgesJsonMap *map[string][]string) func(w http.ResponseWriter, r *http.Request) {
+func HandleMetadataRequest(ethClient *ethereumclient.EthereumClient, polygonClient *ethereumclient.EthereumClient, address string, addressPolygon string, configHolder *config.Holder, badgeStats metadata.BadgeStats, generator *metadata.Generator) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		instanceRoot, err := contracts.NewPolymorph(common.HexToAddress(address), ethClient.Client)
+		configService := configHolder.Current()
+		w.Header().Set(CONFIG_VERSION_HEADER, configService.Version)
+
+		rootTunnelAddress := os.Getenv("ROOT_TUNNEL_ADDRESS")
+
+		tokenId := r.URL.Query().Get("id")
+
+		iTokenId, err := strconv.Atoi(tokenId)
 		if err != nil {
-			render.Status(r, 500)
-			render.JSON(w, r, err)
-			log.Errorln(err)
+			render.Status(r, 400)
+			render.JSON(w, r, fmt.Sprintf("id must be an integer, got %q", tokenId))
 			return
 		}
 
-		instanceChild, err := contracts.NewPolymorphChild(common.HexToAddress(addressPolygon), polygonClient.Client)
+		legacy, err := parseLegacyParam(r, generator.LegacyFields)
 		if err != nil {
-			render.Status(r, 500)
-			render.JSON(w, r, err)
-			log.Errorln(err)
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
 			return
 		}
 
-		rootTunnelAddress := os.Getenv("ROOT_TUNNEL_ADDRESS")
+		genomeInt, err := ethereumclient.GenomeOf(ethClient, polygonClient, address, addressPolygon, rootTunnelAddress, big.NewInt(int64(iTokenId)))
+		if errors.Is(err, ethereumclient.ErrTokenNotFound) {
+			render.Status(r, 404)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		} else if err != nil {
+			// the node failed, not the token
+			render.Status(r, 502)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		}
 
-		tokenId := r.URL.Query().Get("id")
+		g := metadata.Genome(genomeInt.String())
 
-		iTokenId, err := strconv.Atoi(tokenId)
-		if err != nil {
+		m, err := (&g).Metadata(r.Context(), tokenId, configService, badgeStats, generator)
+		var decodeErr *metadata.DecodeError
+		if errors.As(err, &decodeErr) {
+			// the genome of the token has no traits in this config
+			render.Status(r, 422)
+			render.JSON(w, r, err.Error())
+			log.Warnf("Token %s, config version %s: %v", tokenId, configService.Version, err)
+			return
+		} else if err != nil {
 			render.Status(r, 500)
-			render.JSON(w, r, err)
-			log.Errorln(err)
+			render.JSON(w, r, err.Error())
+			log.Errorf("Token %s, config version %s: %v", tokenId, configService.Version, err)
 			return
 		}
 
-		ownerOf, err := instanceRoot.OwnerOf(nil, big.NewInt(int64(iTokenId)))
+		if !legacy {
+			m = m.Standard()
+		}
+		render.JSON(w, r, m)
+	}
+}
 
-		var genomeInt *big.Int
+// parseLegacyParam reads the legacy query parameter, which overrides METADATA_LEGACY_FIELDS for the request.
+func parseLegacyParam(r *http.Request, def bool) (bool, error) {
+	param := r.URL.Query().Get("legacy")
+	if param == "" {
+		return def, nil
+	}
+	legacy, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, errors.New("legacy must be true or false")
+	}
+	return legacy, nil
+}
 
-		if ownerOf == common.HexToAddress("0x0000000000000000000000000000000000000000") {
-			msg := "Query for non-existing token"
-			render.Status(r, 404)
-			render.JSON(w, r, msg)
-			log.Errorln(msg)
-			return
-		} else if ownerOf == common.HexToAddress(rootTunnelAddress) {
-			genomeInt, err = instanceChild.GeneOf(nil, big.NewInt(int64(iTokenId)))
-			if err != nil {
-				render.Status(r, 500)
-				render.JSON(w, r, err)
-				log.Errorln(err)
-				return
-			}
-		} else {
-			genomeInt, err = instanceRoot.GeneOf(nil, big.NewInt(int64(iTokenId)))
-			if err != nil {
-				render.Status(r, 500)
-				render.JSON(w, r, err)
-				log.Errorln(err)
-				return
-			}
+// NewBadgeStatsFromEnv returns the badge stats stored in the BADGES_COLLECTION collection of POLYMORPH_DB,
+// or nil when BADGES_COLLECTION is not set.
+func NewBadgeStatsFromEnv() metadata.BadgeStats {
+	collection := os.Getenv("BADGES_COLLECTION")
+	if collection == "" {
+		return nil
+	}
+
+	badgeStats, err := db.NewBadgeStats(os.Getenv("POLYMORPH_DB"), collection)
+	if err != nil {
+		log.Errorf("Badge holders won't be counted: %v", err)
+		return nil
+	}
+	return badgeStats
+}
+
+// UseArweaveAssetsFromEnv replaces the layer stores of the generator with the assets manifest referenced by the
+// arweaveAssetsJSON of CONTRACT_ADDRESS when ARWEAVE_ASSETS_LAYERS is true. The 2D layers are read under the
+// ARWEAVE_ASSETS_V1_PREFIX path of the manifest and the 3D ones under ARWEAVE_ASSETS_V2_PREFIX.
+func UseArweaveAssetsFromEnv(ctx context.Context, generator *metadata.Generator) error {
+	if use, _ := strconv.ParseBool(os.Getenv("ARWEAVE_ASSETS_LAYERS")); !use {
+		return nil
+	}
+
+	client, err := ethereumclient.NewEthereumClient(os.Getenv("NODE_URL"))
+	if err != nil {
+		return fmt.Errorf("ARWEAVE_ASSETS_LAYERS: %w", err)
+	}
+	ref, err := ethereumclient.ArweaveAssetsJSON(client, os.Getenv("CONTRACT_ADDRESS"))
+	if err != nil {
+		return fmt.Errorf("arweaveAssetsJSON: %w", err)
+	}
+	id := arweave.ParseID(ref)
+	if id == "" {
+		return fmt.Errorf("arweaveAssetsJSON: %q is not an Arweave id or URL", ref)
+	}
+
+	for _, s := range []struct {
+		store  *storage.ObjectStore
+		prefix string
+	}{
+		{&generator.Layers2D, "ARWEAVE_ASSETS_V1_PREFIX"},
+		{&generator.Layers3D, "ARWEAVE_ASSETS_V2_PREFIX"},
+	} {
+		store, err := storage.NewArweaveStore(ctx, os.Getenv("ARWEAVE_GATEWAY_URL"), id, os.Getenv(s.prefix), "")
+		if err != nil {
+			return fmt.Errorf("arweave assets %s: %w", id, err)
 		}
+		*s.store = store
+	}
 
-		g := metadata.Genome(genomeInt.String())
+	log.Infof("Reading the layers from the Arweave assets manifest %s", id)
+	return nil
+}
+
+// NewExistenceCacheFromEnv returns the rendered objects stored in the RENDERED_COLLECTION collection of POLYMORPH_DB,
+// or nil when RENDERED_COLLECTION is not set.
+func NewExistenceCacheFromEnv() storage.ExistenceCache {
+	collection := os.Getenv("RENDERED_COLLECTION")
+	if collection == "" {
+		return nil
+	}
+
+	rendered, err := db.NewRenderedObjects(os.Getenv("POLYMORPH_DB"), collection)
+	if err != nil {
+		log.Errorf("Rendered objects won't be shared: %v", err)
+		return nil
+	}
+	return rendered
+}
+
+// NewRenderJobStoreFromEnv returns the render jobs stored in the RENDER_JOBS_COLLECTION collection of POLYMORPH_DB,
+// or nil when RENDER_JOBS_COLLECTION is not set.
+func NewRenderJobStoreFromEnv() metadata.RenderJobStore {
+	collection := os.Getenv("RENDER_JOBS_COLLECTION")
+	if collection == "" {
+		return nil
+	}
 
-		render.JSON(w, r, (&g).Metadata(tokenId, configService, badgesJsonMap))
+	renderJobs, err := db.NewRenderJobs(os.Getenv("POLYMORPH_DB"), collection)
+	if err != nil {
+		log.Errorf("Render jobs won't be persisted: %v", err)
+		return nil
 	}
+	return renderJobs
 }
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	log "github.com/sirupsen/logrus"
+)
+
+// RENDER_PATH is the path of the render status, followed by the genome.
+const RENDER_PATH = "/render/"
+
+// HandleRenderStatusRequest returns the render job of the genome of GET /render/{genome}.
+// Genomes that were never rendered nor queued are a 404.
+func HandleRenderStatusRequest(configHolder *config.Holder, generator *metadata.Generator) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		configService := configHolder.Current()
+
+		genome := metadata.Genome(strings.TrimPrefix(r.URL.Path, RENDER_PATH))
+		decoded, err := genome.Decode(configService.Slots)
+		if err != nil {
+			render.Status(r, 400)
+			render.JSON(w, r, err.Error())
+			return
+		}
+
+		job, err := generator.RenderStatus(r.Context(), decoded)
+		if err != nil {
+			render.Status(r, 500)
+			render.JSON(w, r, err.Error())
+			log.Errorln(err)
+			return
+		}
+		if job.Status == metadata.RENDER_STATUS_MISSING {
+			render.Status(r, 404)
+		}
+		render.JSON(w, r, job)
+	}
+}
@@ -1,58 +1,28 @@
 package routers
 
 import (
-	"math/big"
 	"net/http"
-	"strconv"
 
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/go-chi/chi"
-	"github.com/go-chi/render"
 	"github.com/polymorph-metadata/app/config"
-	"github.com/polymorph-metadata/app/contracts"
 	"github.com/polymorph-metadata/app/domain/metadata"
 	"github.com/polymorph-metadata/app/interface/api/handlers"
 	"github.com/polymorph-metadata/app/interface/dlt/ethereum"
-	log "github.com/sirupsen/logrus"
 )
 
-func HandleMetadataRequest(ethClient *ethereum.EthereumClient, address string, configService *config.ConfigService, badgesJsonMap *map[string][]string) func(w http.ResponseWriter, r *http.Request) {
+// HandleMetadataRequest serves GET /{tokenId} with the handler of the Cloud Function, which reads the id query param.
+func HandleMetadataRequest(ethClient *ethereumclient.EthereumClient, polygonClient *ethereumclient.EthereumClient, address string, addressPolygon string, configHolder *config.Holder, badgeStats metadata.BadgeStats, generator *metadata.Generator) func(w http.ResponseWriter, r *http.Request) {
+	handler := handlers.HandleMetadataRequest(ethClient, polygonClient, address, addressPolygon, configHolder, badgeStats, generator)
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		instance, err := contracts.NewPolymorph(common.HexToAddress(address), ethClient.Client)
-		if err != nil {
-			render.Status(r, 500)
-			render.JSON(w, r, err)
-			log.Errorln(err)
-			return
-		}
-
-		tokenId := chi.URLParam(r, "tokenId")
-
-		iTokenId, err := strconv.Atoi(tokenId)
-		if err != nil {
-			render.Status(r, 500)
-			render.JSON(w, r, err)
-			log.Errorln(err)
-			return
-		}
-
-		genomeInt, err := instance.GeneOf(nil, big.NewInt(int64(iTokenId)))
-		if err != nil {
-			render.Status(r, 500)
-			render.JSON(w, r, err)
-			log.Errorln(err)
-			return
-		}
-
-		rarity := handlers.GetRarityById(iTokenId)
-		g := metadata.Genome(genomeInt.String())
-		render.JSON(w, r, (&g).Metadata(tokenId, configService, rarity, badgesJsonMap))
+		q := r.URL.Query()
+		q.Set("id", chi.URLParam(r, "tokenId"))
+		r.URL.RawQuery = q.Encode()
+		handler(w, r)
 	}
 }
 
-func NewMetadataRouter(ethClient *ethereum.EthereumClient, address string, configService *config.ConfigService, badgesJsonMap *map[string][]string) http.Handler {
+func NewMetadataRouter(ethClient *ethereumclient.EthereumClient, polygonClient *ethereumclient.EthereumClient, address string, addressPolygon string, configHolder *config.Holder, badgeStats metadata.BadgeStats, generator *metadata.Generator) http.Handler {
 	r := chi.NewRouter()
-	r.Get("/{tokenId}", HandleMetadataRequest(ethClient, address, configService, badgesJsonMap))
+	r.Get("/{tokenId}", HandleMetadataRequest(ethClient, polygonClient, address, addressPolygon, configHolder, badgeStats, generator))
 	return r
 }
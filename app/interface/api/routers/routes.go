@@ -0,0 +1,29 @@
+package routers
+
+import (
+	"net/http"
+
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/interface/api/handlers"
+)
+
+// Route is a path served the same way by the Cloud Functions entrypoint of cmd and the chi API of cmd api.
+type Route struct {
+	// Path is an exact path, or a path prefix when it ends with "/"
+	Path    string
+	Handler http.HandlerFunc
+	// Cacheable routes set their own cache headers
+	Cacheable bool
+}
+
+// Routes returns the routes shared by both entrypoints. /token isn't one of them, as the API reads the token id
+// from the path and the Cloud Function from the id query param.
+func Routes(configHolder *config.Holder, generator *metadata.Generator, persistImages bool) []Route {
+	return []Route{
+		{Path: "/genome", Handler: handlers.HandleGenomeRequest(configHolder, generator)},
+		{Path: handlers.RENDER_PATH, Handler: handlers.HandleRenderStatusRequest(configHolder, generator)},
+		{Path: handlers.IMAGE_PATH, Handler: handlers.HandleImageRequest(configHolder, generator, persistImages), Cacheable: true},
+		{Path: "/admin/reload-config", Handler: handlers.HandleConfigReloadRequest(configHolder)},
+	}
+}
@@ -22,7 +22,7 @@ type txReceiptResponse struct {
 	Receipt *txreceipt.TxReceipt `json:"receipt"`
 }
 
-func handleReceiptRequest(ethClient *ethereum.EthereumClient) func(w http.ResponseWriter, r *http.Request) {
+func handleReceiptRequest(ethClient *ethereumclient.EthereumClient) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var txReceiptRequest *TxReceiptRequest
 
@@ -50,7 +50,7 @@ func handleReceiptRequest(ethClient *ethereum.EthereumClient) func(w http.Respon
 	}
 }
 
-func NewTxReceiptRouter(ethClient *ethereum.EthereumClient) http.Handler {
+func NewTxReceiptRouter(ethClient *ethereumclient.EthereumClient) http.Handler {
 	r := chi.NewRouter()
 	r.Post("/", handleReceiptRequest(ethClient))
 	return r
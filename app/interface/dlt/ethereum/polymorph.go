@@ -0,0 +1,72 @@
+package ethereumclient
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/polymorph-metadata/app/contracts"
+)
+
+var ErrTokenNotFound = errors.New("Query for non-existing token")
+
+// GenomeOf reads the genome of the token on the root chain, or on the child chain when the token
+// is locked in the root tunnel.
+func GenomeOf(rootClient *EthereumClient, childClient *EthereumClient, rootAddress string, childAddress string, rootTunnelAddress string, tokenId *big.Int) (*big.Int, error) {
+	instanceRoot, err := contracts.NewPolymorph(common.HexToAddress(rootAddress), rootClient.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerOf, err := instanceRoot.OwnerOf(nil, tokenId)
+	if isRevert(err) || (err == nil && ownerOf == (common.Address{})) {
+		return nil, ErrTokenNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("ownerOf %s: %w", tokenId, err)
+	}
+
+	if ownerOf == common.HexToAddress(rootTunnelAddress) {
+		instanceChild, err := contracts.NewPolymorphChild(common.HexToAddress(childAddress), childClient.Client)
+		if err != nil {
+			return nil, err
+		}
+		return instanceChild.GeneOf(nil, tokenId)
+	}
+
+	return instanceRoot.GeneOf(nil, tokenId)
+}
+
+// isRevert tells whether the call reverted, which ownerOf only does for tokens that don't exist, e.g.
+// "execution reverted: ERC721: owner query for nonexistent token", rather than failed to reach the node.
+func isRevert(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "execution reverted") || strings.Contains(err.Error(), "nonexistent token"))
+}
+
+// TotalSupply returns the number of tokens minted on the root chain.
+func TotalSupply(rootClient *EthereumClient, rootAddress string) (*big.Int, error) {
+	instance, err := contracts.NewPolymorphRoot(common.HexToAddress(rootAddress), rootClient.Client)
+	if err != nil {
+		return nil, err
+	}
+	return instance.TotalSupply(nil)
+}
+
+// TokenByIndex returns the id of the token at index, from 0 to TotalSupply-1.
+func TokenByIndex(rootClient *EthereumClient, rootAddress string, index *big.Int) (*big.Int, error) {
+	instance, err := contracts.NewPolymorphRoot(common.HexToAddress(rootAddress), rootClient.Client)
+	if err != nil {
+		return nil, err
+	}
+	return instance.TokenByIndex(nil, index)
+}
+
+// ArweaveAssetsJSON returns the reference to the assets manifest published on Arweave by the root contract.
+func ArweaveAssetsJSON(rootClient *EthereumClient, rootAddress string) (string, error) {
+	instance, err := contracts.NewPolymorphRoot(common.HexToAddress(rootAddress), rootClient.Client)
+	if err != nil {
+		return "", err
+	}
+	return instance.ArweaveAssetsJSON(nil)
+}
@@ -0,0 +1,39 @@
+package ipfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/polymorph-metadata/app/cache"
+)
+
+// DEFAULT_PIN_CACHE_SIZE is the number of pinned contents remembered by a CachedPinner.
+const DEFAULT_PIN_CACHE_SIZE = 10000
+
+// CachedPinner remembers the CID of the contents it pinned, by content hash, so that the same content
+// is only uploaded once per process.
+type CachedPinner struct {
+	Pinner
+	pinned *cache.LRU
+}
+
+func NewCachedPinner(pinner Pinner, size int) *CachedPinner {
+	return &CachedPinner{Pinner: pinner, pinned: cache.NewLRU(size)}
+}
+
+func (p *CachedPinner) Pin(ctx context.Context, content []byte, meta PinMetadata) (string, error) {
+	sum := sha256.Sum256(content)
+	key := hex.EncodeToString(sum[:])
+
+	if cid, ok := p.pinned.Get(key); ok {
+		return cid.(string), nil
+	}
+
+	cid, err := p.Pinner.Pin(ctx, content, meta)
+	if err != nil {
+		return "", err
+	}
+	p.pinned.Add(key, cid)
+	return cid, nil
+}
@@ -0,0 +1,196 @@
+package ipfs
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// CHUNK_SIZE is the size of the leaves of the files added to IPFS, the default chunker of Kubo and Pinata.
+const CHUNK_SIZE = 256 * 1024
+
+// LINKS_PER_NODE is the number of children of the inner nodes of the balanced file layout.
+const LINKS_PER_NODE = 174
+
+// Multicodec and multihash codes of the CIDs.
+const (
+	codecRaw    = 0x55
+	codecDagPB  = 0x70
+	hashSHA256  = 0x12
+	unixfsRaw   = 0
+	unixfsFile  = 2
+	base58Chars = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+var base32Lower = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// ComputeCID returns the CID that adding the content as a file gives with the default options of Kubo:
+// a balanced UnixFS DAG of 256KiB chunks. Version 0 gives a base58 "Qm..." CID, version 1 a base32 "bafy..."
+// CID with raw leaves, as `ipfs add --cid-version=1` does.
+func ComputeCID(content []byte, version int) (string, error) {
+	switch version {
+	case 0:
+		node := buildFile(content, false)
+		return base58Encode(node.hash), nil
+	case 1:
+		node := buildFile(content, true)
+		return "b" + base32Lower.EncodeToString(node.cid()), nil
+	default:
+		return "", fmt.Errorf("unsupported CID version %d", version)
+	}
+}
+
+// CIDVersion returns the version of the CID from its encoding, 0 for a base58 "Qm..." CID and 1 for a base32
+// "b..." one, or -1 for another encoding.
+func CIDVersion(cid string) int {
+	switch {
+	case len(cid) == 46 && strings.HasPrefix(cid, "Qm"):
+		return 0
+	case strings.HasPrefix(cid, "b"):
+		return 1
+	}
+	return -1
+}
+
+// dagNode is a block of the file DAG.
+type dagNode struct {
+	// hash is the sha2-256 multihash of the block
+	hash  []byte
+	codec int
+	// fileSize is the size of the file content under the node, treeSize the size of every block under it
+	fileSize uint64
+	treeSize uint64
+}
+
+func (n dagNode) cid() []byte {
+	return append(append([]byte{1}, uvarint(uint64(n.codec))...), n.hash...)
+}
+
+// link is the encoded CID of the node, a bare multihash for CIDv0.
+func (n dagNode) link(v1 bool) []byte {
+	if v1 {
+		return n.cid()
+	}
+	return n.hash
+}
+
+func buildFile(content []byte, rawLeaves bool) dagNode {
+	var nodes []dagNode
+	for offset := 0; offset == 0 || offset < len(content); offset += CHUNK_SIZE {
+		end := offset + CHUNK_SIZE
+		if end > len(content) {
+			end = len(content)
+		}
+		nodes = append(nodes, leafNode(content[offset:end], rawLeaves, offset == 0))
+	}
+
+	for len(nodes) > 1 {
+		var parents []dagNode
+		for i := 0; i < len(nodes); i += LINKS_PER_NODE {
+			end := i + LINKS_PER_NODE
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			parents = append(parents, innerNode(nodes[i:end], rawLeaves))
+		}
+		nodes = parents
+	}
+
+	return nodes[0]
+}
+
+// leafNode encodes a chunk. Like the importer of Kubo, the first leaf is a UnixFS file, so that a single chunk file
+// is its own root, and the next ones are UnixFS raw nodes.
+func leafNode(chunk []byte, raw bool, first bool) dagNode {
+	if raw {
+		return dagNode{hash: multihash(chunk), codec: codecRaw, fileSize: uint64(len(chunk)), treeSize: uint64(len(chunk))}
+	}
+
+	var data []byte
+	if first {
+		data = protoVarint(data, 1, unixfsFile)
+	} else {
+		data = protoVarint(data, 1, unixfsRaw)
+	}
+	if len(chunk) > 0 {
+		data = protoBytes(data, 2, chunk)
+	}
+	data = protoVarint(data, 3, uint64(len(chunk)))
+
+	block := protoBytes(nil, 1, data)
+	return dagNode{hash: multihash(block), codec: codecDagPB, fileSize: uint64(len(chunk)), treeSize: uint64(len(block))}
+}
+
+func innerNode(children []dagNode, v1 bool) dagNode {
+	var data []byte
+	var fileSize, treeSize uint64
+	data = protoVarint(data, 1, unixfsFile)
+	for _, child := range children {
+		fileSize += child.fileSize
+	}
+	data = protoVarint(data, 3, fileSize)
+	for _, child := range children {
+		data = protoVarint(data, 4, child.fileSize)
+	}
+
+	var block []byte
+	for _, child := range children {
+		var link []byte
+		link = protoBytes(link, 1, child.link(v1))
+		link = protoBytes(link, 2, nil)
+		link = protoVarint(link, 3, child.treeSize)
+		block = protoBytes(block, 2, link)
+		treeSize += child.treeSize
+	}
+	block = protoBytes(block, 1, data)
+
+	return dagNode{hash: multihash(block), codec: codecDagPB, fileSize: fileSize, treeSize: treeSize + uint64(len(block))}
+}
+
+func multihash(block []byte) []byte {
+	sum := sha256.Sum256(block)
+	return append([]byte{hashSHA256, sha256.Size}, sum[:]...)
+}
+
+// protoVarint and protoBytes append a protobuf field.
+func protoVarint(b []byte, field int, v uint64) []byte {
+	return append(append(b, byte(field<<3)), uvarint(v)...)
+}
+
+func protoBytes(b []byte, field int, v []byte) []byte {
+	b = append(append(b, byte(field<<3|2)), uvarint(uint64(len(v)))...)
+	return append(b, v...)
+}
+
+func uvarint(v uint64) []byte {
+	var res []byte
+	for v >= 0x80 {
+		res = append(res, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(res, byte(v))
+}
+
+func base58Encode(b []byte) string {
+	n := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var res []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		res = append(res, base58Chars[mod.Int64()])
+	}
+	for _, c := range b {
+		if c != 0 {
+			break
+		}
+		res = append(res, base58Chars[0])
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return string(res)
+}
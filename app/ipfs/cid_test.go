@@ -0,0 +1,133 @@
+package ipfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func TestComputeCID(t *testing.T) {
+	// CIDs given by `ipfs add` and `ipfs add --cid-version=1`
+	tests := []struct {
+		name    string
+		content string
+		version int
+		cid     string
+	}{
+		{"empty v0", "", 0, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"},
+		{"empty v1", "", 1, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"},
+		{"hello world v0", "hello world", 0, "Qmf412jQZiuVUtdgnB36FXFX7xg5V6KEbSJ4dpQuhkLyfD"},
+		{"hello world v1", "hello world", 1, "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"},
+		{"hello world newline v0", "hello world\n", 0, "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o"},
+		{"hello world newline v1", "hello world\n", 1, "bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cid, err := ComputeCID([]byte(tt.content), tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cid != tt.cid {
+				t.Errorf("ComputeCID = %s, want %s", cid, tt.cid)
+			}
+		})
+	}
+
+	if _, err := ComputeCID(nil, 2); err == nil {
+		t.Error("ComputeCID of version 2 succeeded, want an error")
+	}
+}
+
+// TestComputeCIDMultiChunk checks a file of two chunks against its root block, encoded here field by field as the
+// importer of Kubo writes it: the links first, then the UnixFS data with the file size and the size of each chunk.
+func TestComputeCIDMultiChunk(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), CHUNK_SIZE+1)
+	chunks := [][]byte{content[:CHUNK_SIZE], content[CHUNK_SIZE:]}
+
+	varint := func(v int) []byte {
+		b := make([]byte, binary.MaxVarintLen64)
+		return b[:binary.PutUvarint(b, uint64(v))]
+	}
+	field := func(tag byte, v []byte) []byte {
+		return append(append([]byte{tag}, varint(len(v))...), v...)
+	}
+	sum := func(b []byte) []byte {
+		s := sha256.Sum256(b)
+		return append([]byte{0x12, 0x20}, s[:]...)
+	}
+	root := func(hashes [][]byte, sizes []int) []byte {
+		var block []byte
+		for i, hash := range hashes {
+			link := append(field(0x0a, hash), 0x12, 0x00, 0x18)
+			block = append(block, field(0x12, append(link, varint(sizes[i])...))...)
+		}
+		data := append([]byte{0x08, 0x02, 0x18}, varint(len(content))...)
+		for _, chunk := range chunks {
+			data = append(append(data, 0x20), varint(len(chunk))...)
+		}
+		return append(block, field(0x0a, data)...)
+	}
+
+	t.Run("v0", func(t *testing.T) {
+		// the first leaf is a UnixFS file, the next ones UnixFS raw nodes
+		var hashes [][]byte
+		var sizes []int
+		for i, chunk := range chunks {
+			unixfsType := byte(0x00)
+			if i == 0 {
+				unixfsType = 0x02
+			}
+			data := append(append([]byte{0x08, unixfsType}, field(0x12, chunk)...), 0x18)
+			leaf := field(0x0a, append(data, varint(len(chunk))...))
+			hashes = append(hashes, sum(leaf))
+			sizes = append(sizes, len(leaf))
+		}
+		want := base58Encode(sum(root(hashes, sizes)))
+
+		cid, err := ComputeCID(content, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid != want {
+			t.Errorf("ComputeCID = %s, want %s", cid, want)
+		}
+	})
+
+	t.Run("v1", func(t *testing.T) {
+		// raw leaves, linked by their CIDv1
+		var hashes [][]byte
+		var sizes []int
+		for _, chunk := range chunks {
+			hashes = append(hashes, append([]byte{0x01, 0x55}, sum(chunk)...))
+			sizes = append(sizes, len(chunk))
+		}
+		want := "b" + base32Lower.EncodeToString(append([]byte{0x01, 0x70}, sum(root(hashes, sizes))...))
+
+		cid, err := ComputeCID(content, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cid != want {
+			t.Errorf("ComputeCID = %s, want %s", cid, want)
+		}
+	})
+}
+
+func TestCIDVersion(t *testing.T) {
+	tests := []struct {
+		cid     string
+		version int
+	}{
+		{"QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o", 0},
+		{"bafkreifjjcie6lypi6ny7amxnfftagclbuxndqonfipmb64f2km2devei4", 1},
+		{"zb2rhe5P4gXftAwvA4eXQ5HJwsER2owDyS9sKaQRRVQPn93bA", -1},
+		{"", -1},
+	}
+	for _, tt := range tests {
+		if version := CIDVersion(tt.cid); version != tt.version {
+			t.Errorf("CIDVersion(%q) = %d, want %d", tt.cid, version, tt.version)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DEFAULT_KUBO_API_URL is the RPC API of a local IPFS node.
+const DEFAULT_KUBO_API_URL = "http://127.0.0.1:5001"
+
+// Kubo pins content on an IPFS node through its HTTP RPC API. The node keeps no metadata other than the pin name.
+type Kubo struct {
+	BaseURL string
+	// CIDVersion is the version of the CIDs of the added content, 0 or 1 with raw leaves
+	CIDVersion int
+	client     *http.Client
+}
+
+type kuboAddResponse struct {
+	Hash string `json:"Hash"`
+}
+
+type kuboError struct {
+	Message string `json:"Message"`
+}
+
+// NewKubo returns a pinner for the node at apiURL, DEFAULT_KUBO_API_URL when empty.
+func NewKubo(apiURL string) *Kubo {
+	if apiURL == "" {
+		apiURL = DEFAULT_KUBO_API_URL
+	}
+	return &Kubo{BaseURL: strings.TrimSuffix(apiURL, "/"), client: &http.Client{Timeout: time.Minute}}
+}
+
+// Pin hashes the content first and only uploads it when its CID is not pinned yet. The pin is named after
+// the token id, or the file name without token id.
+func (k *Kubo) Pin(ctx context.Context, content []byte, meta PinMetadata) (string, error) {
+	cid, err := k.add(ctx, content, meta, true)
+	if err != nil {
+		return "", err
+	}
+	if pinned, err := k.IsPinned(ctx, cid); err != nil {
+		return "", err
+	} else if pinned {
+		return cid, nil
+	}
+	return k.add(ctx, content, meta, false)
+}
+
+// add uploads the content and pins it, or only computes its CID when onlyHash is set.
+func (k *Kubo) add(ctx context.Context, content []byte, meta PinMetadata, onlyHash bool) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", meta.Name)
+	if err != nil {
+		return "", &PinError{Backend: "kubo", Op: "pin", Err: err}
+	}
+	part.Write(content)
+	if err := writer.Close(); err != nil {
+		return "", &PinError{Backend: "kubo", Op: "pin", Err: err}
+	}
+
+	name := meta.Name
+	if meta.TokenId != "" {
+		name = "polymorph-" + meta.TokenId
+	}
+	q := url.Values{"cid-version": {strconv.Itoa(k.CIDVersion)}}
+	if onlyHash {
+		q.Set("only-hash", "true")
+	} else {
+		q.Set("pin", "true")
+		q.Set("pin-name", name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.BaseURL+"/api/v0/add?"+q.Encode(), body)
+	if err != nil {
+		return "", &PinError{Backend: "kubo", Op: "pin", Err: err}
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var res kuboAddResponse
+	if err := k.do(req, "pin", &res); err != nil {
+		return "", err
+	}
+	if res.Hash == "" {
+		return "", &PinError{Backend: "kubo", Op: "pin", Message: "no Hash in the response"}
+	}
+	return res.Hash, nil
+}
+
+// IsPinned asks the node for the recursive pin of the CID.
+func (k *Kubo) IsPinned(ctx context.Context, cid string) (bool, error) {
+	q := url.Values{"arg": {cid}, "type": {"recursive"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.BaseURL+"/api/v0/pin/ls?"+q.Encode(), nil)
+	if err != nil {
+		return false, &PinError{Backend: "kubo", Op: "status", Err: err}
+	}
+
+	err = k.do(req, "status", &struct{}{})
+	if pinErr, ok := err.(*PinError); ok && strings.Contains(pinErr.Message, "not pinned") {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (k *Kubo) do(req *http.Request, op string, res interface{}) error {
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return &PinError{Backend: "kubo", Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var kerr kuboError
+		if json.NewDecoder(resp.Body).Decode(&kerr) == nil && kerr.Message != "" {
+			return &PinError{Backend: "kubo", Op: op, StatusCode: resp.StatusCode, Message: kerr.Message}
+		}
+		return &PinError{Backend: "kubo", Op: op, StatusCode: resp.StatusCode}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return &PinError{Backend: "kubo", Op: op, StatusCode: resp.StatusCode, Err: err}
+	}
+	return nil
+}
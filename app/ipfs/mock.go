@@ -0,0 +1,49 @@
+package ipfs
+
+import (
+	"context"
+	"sync"
+)
+
+// Mock keeps the pins in memory, e.g. for local runs without an IPFS node. Its CIDs are the ones IPFS would give.
+type Mock struct {
+	// CIDVersion is the version of the CIDs of the pinned content, 0 or 1
+	CIDVersion int
+
+	mu   sync.Mutex
+	pins map[string]PinMetadata
+	// Calls counts the calls of Pin
+	Calls int
+}
+
+func NewMock() *Mock {
+	return &Mock{pins: map[string]PinMetadata{}}
+}
+
+func (m *Mock) Pin(ctx context.Context, content []byte, meta PinMetadata) (string, error) {
+	cid, err := ComputeCID(content, m.CIDVersion)
+	if err != nil {
+		return "", &PinError{Backend: "mock", Op: "pin", Err: err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls++
+	m.pins[cid] = meta
+	return cid, nil
+}
+
+func (m *Mock) IsPinned(ctx context.Context, cid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.pins[cid]
+	return ok, nil
+}
+
+// Metadata returns the metadata the CID was pinned with.
+func (m *Mock) Metadata(cid string) (PinMetadata, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta, ok := m.pins[cid]
+	return meta, ok
+}
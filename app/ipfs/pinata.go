@@ -0,0 +1,117 @@
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PINATA_API_URL is the base URL of the Pinata API.
+const PINATA_API_URL = "https://api.pinata.cloud"
+
+// Pinata pins content with the Pinata API, authenticated with a JWT or an API key and secret.
+type Pinata struct {
+	// BaseURL defaults to PINATA_API_URL
+	BaseURL string
+	// CIDVersion is the version of the CIDs of the pinned content, 0 or 1
+	CIDVersion int
+	apiKey     string
+	secretKey  string
+	jwt        string
+	client     *http.Client
+}
+
+type pinataPinResponse struct {
+	IPFSHash    string `json:"IpfsHash"`
+	PinSize     int64  `json:"PinSize"`
+	IsDuplicate bool   `json:"isDuplicate"`
+}
+
+type pinataListResponse struct {
+	Count int `json:"count"`
+}
+
+// NewPinata returns a Pinata pinner. jwt takes precedence over the key and secret.
+func NewPinata(apiKey string, secretKey string, jwt string) *Pinata {
+	return &Pinata{BaseURL: PINATA_API_URL, apiKey: apiKey, secretKey: secretKey, jwt: jwt, client: &http.Client{Timeout: time.Minute}}
+}
+
+// Pin uploads the content with pinFileToIPFS. The token id and the genome are attached as key values.
+func (p *Pinata) Pin(ctx context.Context, content []byte, meta PinMetadata) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	part, err := writer.CreateFormFile("file", meta.Name)
+	if err != nil {
+		return "", &PinError{Backend: "pinata", Op: "pin", Err: err}
+	}
+	part.Write(content)
+
+	pinataMetadata, _ := json.Marshal(map[string]interface{}{"name": meta.Name, "keyvalues": meta.keyValues()})
+	writer.WriteField("pinataMetadata", string(pinataMetadata))
+	if p.CIDVersion != 0 {
+		writer.WriteField("pinataOptions", fmt.Sprintf(`{"cidVersion":%d}`, p.CIDVersion))
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", &PinError{Backend: "pinata", Op: "pin", Err: err}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL+"/pinning/pinFileToIPFS", body)
+	if err != nil {
+		return "", &PinError{Backend: "pinata", Op: "pin", Err: err}
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var res pinataPinResponse
+	if err := p.do(req, "pin", &res); err != nil {
+		return "", err
+	}
+	if res.IPFSHash == "" {
+		return "", &PinError{Backend: "pinata", Op: "pin", Message: "no IpfsHash in the response"}
+	}
+	return res.IPFSHash, nil
+}
+
+// IsPinned lists the pins of the CID.
+func (p *Pinata) IsPinned(ctx context.Context, cid string) (bool, error) {
+	q := url.Values{"hashContains": {cid}, "status": {"pinned"}, "pageLimit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"/data/pinList?"+q.Encode(), nil)
+	if err != nil {
+		return false, &PinError{Backend: "pinata", Op: "status", Err: err}
+	}
+
+	var res pinataListResponse
+	if err := p.do(req, "status", &res); err != nil {
+		return false, err
+	}
+	return res.Count > 0, nil
+}
+
+func (p *Pinata) do(req *http.Request, op string, res interface{}) error {
+	if p.jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+p.jwt)
+	} else {
+		req.Header.Set("pinata_api_key", p.apiKey)
+		req.Header.Set("pinata_secret_api_key", p.secretKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return &PinError{Backend: "pinata", Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return responseError("pinata", op, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+		return &PinError{Backend: "pinata", Op: op, StatusCode: resp.StatusCode, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ipfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ErrNotPinned is returned when asking about content that is not pinned.
+var ErrNotPinned = errors.New("content is not pinned")
+
+// Pinner adds content to IPFS and keeps it pinned.
+type Pinner interface {
+	// Pin adds the content, pins it and returns its CID. Content that is already pinned is not pinned again
+	Pin(ctx context.Context, content []byte, meta PinMetadata) (string, error)
+	// IsPinned tells whether the CID is pinned
+	IsPinned(ctx context.Context, cid string) (bool, error)
+}
+
+// PinMetadata describes pinned content, for the backends that keep metadata.
+type PinMetadata struct {
+	// Name is the file name of the content, e.g. iframe-go.html
+	Name    string
+	TokenId string
+	Genome  string
+}
+
+// keyValues returns the metadata other than the name, without empty values.
+func (m PinMetadata) keyValues() map[string]string {
+	res := map[string]string{}
+	if m.TokenId != "" {
+		res["tokenId"] = m.TokenId
+	}
+	if m.Genome != "" {
+		res["genome"] = m.Genome
+	}
+	return res
+}
+
+// PinError is a failed call to a pinning backend.
+type PinError struct {
+	// Backend is the name of the pinner, e.g. "pinata"
+	Backend string
+	// Op is the failed operation, "pin" or "status"
+	Op string
+	// StatusCode is the HTTP status of the response, 0 when there was none
+	StatusCode int
+	// Message is the error returned by the backend
+	Message string
+	Err     error
+}
+
+func (e *PinError) Error() string {
+	msg := fmt.Sprintf("%s %s", e.Backend, e.Op)
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(": HTTP %d", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *PinError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary tells whether the call may succeed when retried.
+func (e *PinError) Temporary() bool {
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// responseError reads the body of a failed response into a PinError.
+func responseError(backend string, op string, resp *http.Response) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	return &PinError{Backend: backend, Op: op, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
+
+// CIDVersionFromEnv returns the CID version of IPFS_CID_VERSION, 0 by default.
+func CIDVersionFromEnv() (int, error) {
+	switch version := os.Getenv("IPFS_CID_VERSION"); version {
+	case "", "0":
+		return 0, nil
+	case "1":
+		return 1, nil
+	default:
+		return 0, fmt.Errorf("IPFS_CID_VERSION: unsupported CID version %q, expected 0 or 1", version)
+	}
+}
+
+// NewPinnerFromEnv returns the pinner named by IPFS_PINNER: "pinata" (the default), "kubo" or "mock".
+// It adds the content as CIDs of the given version, see ComputeCID.
+func NewPinnerFromEnv(cidVersion int) (Pinner, error) {
+	switch name := os.Getenv("IPFS_PINNER"); name {
+	case "", "pinata":
+		pinata := NewPinata(os.Getenv("PINATA_API_KEY"), os.Getenv("PINATA_SECRET_KEY"), os.Getenv("PINATA_JWT"))
+		pinata.CIDVersion = cidVersion
+		return pinata, nil
+	case "kubo":
+		kubo := NewKubo(os.Getenv("IPFS_API_URL"))
+		kubo.CIDVersion = cidVersion
+		return kubo, nil
+	case "mock":
+		mock := NewMock()
+		mock.CIDVersion = cidVersion
+		return mock, nil
+	default:
+		return nil, fmt.Errorf("IPFS_PINNER: unknown pinner %q, expected pinata, kubo or mock", name)
+	}
+}
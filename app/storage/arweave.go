@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/polymorph-metadata/app/arweave"
+)
+
+// ErrReadOnly is returned when writing to a store that can't be written to.
+var ErrReadOnly = errors.New("read-only store")
+
+// ArweaveStore reads the objects listed by an Arweave manifest, e.g. the layers referenced by the arweaveAssetsJSON
+// of the Polymorph contract. Keys are the paths of the manifest under an optional prefix, cleaned with
+// arweave.CleanPath so that the "./images/..." keys of the layers match the "images/..." paths.
+type ArweaveStore struct {
+	gatewayURL string
+	manifestID string
+	prefix     string
+	manifest   *arweave.Manifest
+	publicURL  string
+	client     *http.Client
+}
+
+// NewArweaveStore reads the manifest from the gateway, arweave.DEFAULT_GATEWAY_URL when empty.
+func NewArweaveStore(ctx context.Context, gatewayURL string, manifestID string, prefix string, publicURL string) (*ArweaveStore, error) {
+	if gatewayURL == "" {
+		gatewayURL = arweave.DEFAULT_GATEWAY_URL
+	}
+	gatewayURL = strings.TrimSuffix(gatewayURL, "/")
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	manifest, err := arweave.ReadManifest(ctx, client, gatewayURL, manifestID)
+	if err != nil {
+		return nil, err
+	}
+	if publicURL == "" {
+		publicURL = gatewayURL + "/" + manifestID + "/" + prefix
+	}
+	return &ArweaveStore{gatewayURL: gatewayURL, manifestID: manifestID, prefix: prefix, manifest: manifest, publicURL: publicURL, client: client}, nil
+}
+
+func (s *ArweaveStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, ok := s.manifest.Paths[s.prefix+arweave.CleanPath(key)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return arweave.Fetch(ctx, s.client, s.gatewayURL, path.ID)
+}
+
+func (s *ArweaveStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	return fmt.Errorf("%w: arweave manifest %s", ErrReadOnly, s.manifestID)
+}
+
+// Exists only looks at the manifest.
+func (s *ArweaveStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, ok := s.manifest.Paths[s.prefix+arweave.CleanPath(key)]
+	return ok, nil
+}
+
+func (s *ArweaveStore) URL(key string) string {
+	return objectURL(s.publicURL, arweave.CleanPath(key))
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/polymorph-metadata/app/arweave"
+)
+
+func TestArweaveStoreKeys(t *testing.T) {
+	gateway := arweave.NewMockGateway()
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	// a flat asset list, as the arweaveAssetsJSON of the contract, and a path manifest
+	layer := strings.Repeat("l", 43)
+	gateway.Put(layer, []byte("png"), "image/png")
+	gateway.Put("assets", []byte(`{"/v1/images/0/11.png": "ar://`+layer+`"}`), "application/json")
+	gateway.Put("manifest", []byte(`{"manifest": "arweave/paths", "version": "0.2.0", "paths": {"./v1/images/0/11.png": {"id": "`+layer+`"}}}`), arweave.MANIFEST_CONTENT_TYPE)
+
+	tests := []struct {
+		name   string
+		key    string
+		exists bool
+	}{
+		// the keys of the layers, see layerPath of the metadata package
+		{"layer key", "./images/0/11.png", true},
+		{"manifest path", "images/0/11.png", true},
+		{"absolute", "/images/0/11.png", true},
+		{"missing", "./images/0/12.png", false},
+		{"outside the prefix", "../v1/images/0/11.png", false},
+	}
+
+	for _, manifest := range []string{"assets", "manifest"} {
+		store, err := NewArweaveStore(context.Background(), server.URL, manifest, "v1", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range tests {
+			t.Run(manifest+"/"+tt.name, func(t *testing.T) {
+				exists, err := store.Exists(context.Background(), tt.key)
+				if err != nil || exists != tt.exists {
+					t.Errorf("Exists = %t, %v, want %t", exists, err, tt.exists)
+				}
+
+				r, err := store.Get(context.Background(), tt.key)
+				if !tt.exists {
+					if !errors.Is(err, ErrNotFound) {
+						t.Errorf("Get error = %v, want %v", err, ErrNotFound)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer r.Close()
+				if data, _ := ioutil.ReadAll(r); string(data) != "png" {
+					t.Errorf("Get = %q, want %q", data, "png")
+				}
+
+				if url := store.URL(tt.key); url != server.URL+"/"+manifest+"/v1/images/0/11.png" {
+					t.Errorf("URL = %s", url)
+				}
+			})
+		}
+	}
+}
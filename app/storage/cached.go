@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"io"
+
+	"github.com/polymorph-metadata/app/cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// DEFAULT_EXISTENCE_CACHE_SIZE is the number of existing objects remembered by a CachedStore.
+const DEFAULT_EXISTENCE_CACHE_SIZE = 10000
+
+// ExistenceCache remembers existing objects across the instances of the service, by object URL.
+type ExistenceCache interface {
+	Has(ctx context.Context, url string) (bool, error)
+	Add(ctx context.Context, url string) error
+}
+
+// CachedStore remembers the objects known to exist, in memory and in an optional shared cache.
+// Only existence is cached: the pipeline never deletes objects, so it can't go stale.
+type CachedStore struct {
+	ObjectStore
+	known  *cache.LRU
+	shared ExistenceCache
+}
+
+// NewCachedStore wraps store. shared may be nil.
+func NewCachedStore(store ObjectStore, size int, shared ExistenceCache) *CachedStore {
+	return &CachedStore{ObjectStore: store, known: cache.NewLRU(size), shared: shared}
+}
+
+// Exists asks the store only when neither cache knows the object. A failing shared cache is logged and skipped.
+func (s *CachedStore) Exists(ctx context.Context, key string) (bool, error) {
+	if _, ok := s.known.Get(key); ok {
+		return true, nil
+	}
+
+	if s.shared != nil {
+		exists, err := s.shared.Has(ctx, s.URL(key))
+		if err != nil {
+			log.Warnf("Existence cache %s: %v", s.URL(key), err)
+		} else if exists {
+			s.known.Add(key, true)
+			return true, nil
+		}
+	}
+
+	exists, err := s.ObjectStore.Exists(ctx, key)
+	if err != nil || !exists {
+		return exists, err
+	}
+	s.remember(ctx, key)
+	return true, nil
+}
+
+func (s *CachedStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	if err := s.ObjectStore.Put(ctx, key, r, contentType); err != nil {
+		return err
+	}
+	s.remember(ctx, key)
+	return nil
+}
+
+func (s *CachedStore) remember(ctx context.Context, key string) {
+	s.known.Add(key, true)
+	if s.shared != nil {
+		if err := s.shared.Add(ctx, s.URL(key)); err != nil {
+			log.Warnf("Existence cache %s: %v", s.URL(key), err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	gcs "cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// GCS_PUBLIC_URL is the default public base URL of a Google Cloud Storage bucket.
+const GCS_PUBLIC_URL = "https://storage.googleapis.com/"
+
+// GCSStore is a Google Cloud Storage bucket.
+type GCSStore struct {
+	bucket    *gcs.BucketHandle
+	publicURL string
+}
+
+// NewGCSStore opens the bucket with the default credentials.
+func NewGCSStore(ctx context.Context, bucketName string, publicURL string) (*GCSStore, error) {
+	client, err := gcs.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("storage.NewClient: %w", err)
+	}
+	if publicURL == "" {
+		publicURL = GCS_PUBLIC_URL + bucketName
+	}
+	return &GCSStore{bucket: client.Bucket(bucketName), publicURL: publicURL}, nil
+}
+
+func (s *GCSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	r, err := s.bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return r, err
+}
+
+func (s *GCSStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	w := s.bucket.Object(key).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// Exists reads the attributes of the object, without downloading it.
+func (s *GCSStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.bucket.Object(key).Attrs(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// PutIfAbsent writes the object with a does-not-exist precondition.
+func (s *GCSStore) PutIfAbsent(ctx context.Context, key string, data []byte, contentType string) (int64, error) {
+	w := s.bucket.Object(key).If(gcs.Conditions{DoesNotExist: true}).NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return 0, err
+	}
+
+	err := w.Close()
+	var apiError *googleapi.Error
+	if errors.As(err, &apiError) && apiError.Code == http.StatusPreconditionFailed {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return w.Attrs().Generation, nil
+}
+
+// GetGeneration reads the object along with the GCS generation of its content.
+func (s *GCSStore) GetGeneration(ctx context.Context, key string) ([]byte, int64, error) {
+	r, err := s.bucket.Object(key).NewReader(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotFound, key)
+	} else if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	return data, r.Attrs.Generation, err
+}
+
+// DeleteIfGeneration deletes the object with a generation-match precondition.
+func (s *GCSStore) DeleteIfGeneration(ctx context.Context, key string, generation int64) (bool, error) {
+	err := s.bucket.Object(key).If(gcs.Conditions{GenerationMatch: generation}).Delete(ctx)
+	var apiError *googleapi.Error
+	switch {
+	case errors.Is(err, gcs.ErrObjectNotExist):
+		return false, nil
+	case errors.As(err, &apiError) && apiError.Code == http.StatusPreconditionFailed:
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *GCSStore) Delete(ctx context.Context, key string) error {
+	err := s.bucket.Object(key).Delete(ctx)
+	if errors.Is(err, gcs.ErrObjectNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return err
+}
+
+func (s *GCSStore) URL(key string) string {
+	return objectURL(s.publicURL, key)
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DEFAULT_LEASE_TTL is how long a lease is held before other instances may break it, e.g. after a crash.
+const DEFAULT_LEASE_TTL = 2 * time.Minute
+
+// LEASE_POLL_INTERVAL is how often a held lease is checked again.
+const LEASE_POLL_INTERVAL = 500 * time.Millisecond
+
+// LeaseStore is a store that can create an object only when it doesn't exist yet, and delete it only when it
+// wasn't replaced since it was read. Every write of an object gets a new generation.
+type LeaseStore interface {
+	ObjectStore
+	// PutIfAbsent writes the object and returns its generation, or returns 0 when it already exists
+	PutIfAbsent(ctx context.Context, key string, data []byte, contentType string) (int64, error)
+	// GetGeneration reads the object and its generation
+	GetGeneration(ctx context.Context, key string) ([]byte, int64, error)
+	// DeleteIfGeneration deletes the object when it still has the generation, and tells whether it did
+	DeleteIfGeneration(ctx context.Context, key string, generation int64) (bool, error)
+}
+
+// LeaseLocker is a lock shared by every instance of the service, held as lease objects of a store.
+// A lease older than its TTL is broken, so the TTL must be longer than the work it guards. Leases are only
+// deleted at the generation they were acquired or read at, so that breaking or releasing a lease never deletes
+// a lease another instance took in the meantime.
+type LeaseLocker struct {
+	store LeaseStore
+	ttl   time.Duration
+}
+
+// NewLeaseLocker returns a locker of the store, which must support conditional writes (GCS and local stores).
+func NewLeaseLocker(store ObjectStore, ttl time.Duration) (*LeaseLocker, error) {
+	leases, ok := store.(LeaseStore)
+	if !ok {
+		return nil, fmt.Errorf("storage: %T doesn't support conditional writes", store)
+	}
+	if ttl <= 0 {
+		ttl = DEFAULT_LEASE_TTL
+	}
+	return &LeaseLocker{store: leases, ttl: ttl}, nil
+}
+
+// Lock waits until the lease of the key is acquired and returns its release.
+func (l *LeaseLocker) Lock(ctx context.Context, key string) (func(), error) {
+	leaseKey := "leases/" + strings.ReplaceAll(key, "/", "_")
+
+	for {
+		expiry := time.Now().Add(l.ttl).UTC().Format(time.RFC3339Nano)
+		generation, err := l.store.PutIfAbsent(ctx, leaseKey, []byte(expiry), "text/plain")
+		if err != nil {
+			return nil, err
+		}
+		if generation != 0 {
+			return func() {
+				deleted, err := l.store.DeleteIfGeneration(context.Background(), leaseKey, generation)
+				if err != nil {
+					log.Errorf("Releasing lease %s: %v", leaseKey, err)
+				} else if !deleted {
+					log.Warnf("Lease %s expired and was taken over before its release", leaseKey)
+				}
+			}, nil
+		}
+
+		if generation, ok := l.expired(ctx, leaseKey); ok {
+			deleted, err := l.store.DeleteIfGeneration(ctx, leaseKey, generation)
+			if err != nil {
+				return nil, err
+			}
+			if deleted {
+				log.Warnf("Broke expired lease %s", leaseKey)
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(LEASE_POLL_INTERVAL):
+		}
+	}
+}
+
+// expired returns the generation of the lease when it's past its expiry. Unreadable leases are treated as held.
+func (l *LeaseLocker) expired(ctx context.Context, leaseKey string) (int64, bool) {
+	data, generation, err := l.store.GetGeneration(ctx, leaseKey)
+	if err != nil {
+		return 0, false
+	}
+	expiry, err := time.Parse(time.RFC3339Nano, string(bytes.TrimSpace(data)))
+	return generation, err == nil && time.Now().After(expiry)
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestLeaseStore(t *testing.T) *LocalStore {
+	store, err := NewLocalStore(t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestLocalStoreConditionalWrites(t *testing.T) {
+	ctx := context.Background()
+	store := newTestLeaseStore(t)
+
+	first, err := store.PutIfAbsent(ctx, "leases/a", []byte("1"), "text/plain")
+	if err != nil || first == 0 {
+		t.Fatalf("PutIfAbsent = %d, %v, want a generation", first, err)
+	}
+	if generation, err := store.PutIfAbsent(ctx, "leases/a", []byte("2"), "text/plain"); err != nil || generation != 0 {
+		t.Fatalf("PutIfAbsent of an existing object = %d, %v, want 0", generation, err)
+	}
+	data, generation, err := store.GetGeneration(ctx, "leases/a")
+	if err != nil || string(data) != "1" || generation != first {
+		t.Fatalf("GetGeneration = %q, %d, %v, want \"1\", %d", data, generation, err, first)
+	}
+
+	if deleted, err := store.DeleteIfGeneration(ctx, "leases/a", first); err != nil || !deleted {
+		t.Fatalf("DeleteIfGeneration = %t, %v, want true", deleted, err)
+	}
+	second, err := store.PutIfAbsent(ctx, "leases/a", []byte("3"), "text/plain")
+	if err != nil || second == 0 || second == first {
+		t.Fatalf("PutIfAbsent after delete = %d, %v, want a new generation", second, err)
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		generation int64
+		deleted    bool
+	}{
+		{"stale generation", "leases/a", first, false},
+		{"missing object", "leases/b", first, false},
+		{"current generation", "leases/a", second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted, err := store.DeleteIfGeneration(ctx, tt.key, tt.generation)
+			if err != nil || deleted != tt.deleted {
+				t.Errorf("DeleteIfGeneration = %t, %v, want %t", deleted, err, tt.deleted)
+			}
+		})
+	}
+}
+
+func TestLeaseLockerContention(t *testing.T) {
+	store := newTestLeaseStore(t)
+
+	var active, maxActive, done int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		// every worker has its own locker, like the instances of the service
+		locker, err := NewLeaseLocker(store, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			unlock, err := locker.Lock(ctx, "images/0102")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := atomic.AddInt32(&active, 1)
+			for {
+				m := atomic.LoadInt32(&maxActive)
+				if n <= m || atomic.CompareAndSwapInt32(&maxActive, m, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+			atomic.AddInt32(&done, 1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxActive != 1 || done != 8 {
+		t.Errorf("%d holder(s) at once and %d done, want 1 and 8", maxActive, done)
+	}
+}
+
+func TestLeaseLockerBreaksExpiredLease(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	store := newTestLeaseStore(t)
+
+	crashed, _ := NewLeaseLocker(store, 50*time.Millisecond)
+	releaseCrashed, err := crashed.Lock(ctx, "images/0102")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	live, _ := NewLeaseLocker(store, time.Minute)
+	releaseLive, err := live.Lock(ctx, "images/0102")
+	if err != nil {
+		t.Fatalf("the expired lease was not broken: %v", err)
+	}
+
+	// the late release of the broken lease must not delete the lease of the live holder
+	releaseCrashed()
+	if exists, err := store.Exists(ctx, "leases/images_0102"); err != nil || !exists {
+		t.Fatalf("the live lease was deleted by the release of the broken one")
+	}
+
+	releaseLive()
+	if exists, _ := store.Exists(ctx, "leases/images_0102"); exists {
+		t.Error("the live lease was not released")
+	}
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LocalStore keeps the objects as files under a directory, e.g. to run the pipeline without a cloud account.
+type LocalStore struct {
+	root      string
+	publicURL string
+}
+
+// NewLocalStore creates the directory if needed.
+func NewLocalStore(root string, publicURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	if publicURL == "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		publicURL = "file://" + filepath.ToSlash(abs)
+	}
+	return &LocalStore{root: root, publicURL: publicURL}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return f, err
+}
+
+// Put writes to a temporary file first, so that readers never see a partial object.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// PutIfAbsent links a complete temporary file to the object, which fails when the object exists. The generation
+// of the object is its modification time in nanoseconds, set under the lock of the directory.
+func (s *LocalStore) PutIfAbsent(ctx context.Context, key string, data []byte, contentType string) (int64, error) {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	unlock, err := s.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	generation := time.Now().UnixNano()
+	if err := os.Chtimes(tmp.Name(), time.Unix(0, generation), time.Unix(0, generation)); err != nil {
+		return 0, err
+	}
+	err = os.Link(tmp.Name(), path)
+	if errors.Is(err, fs.ErrExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return generation, nil
+}
+
+// GetGeneration reads the object and its modification time in nanoseconds.
+func (s *LocalStore) GetGeneration(ctx context.Context, key string) ([]byte, int64, error) {
+	f, err := os.Open(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotFound, key)
+	} else if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := ioutil.ReadAll(f)
+	return data, info.ModTime().UnixNano(), err
+}
+
+// DeleteIfGeneration compares the modification time and deletes the object under the lock of the directory.
+func (s *LocalStore) DeleteIfGeneration(ctx context.Context, key string, generation int64) (bool, error) {
+	unlock, err := s.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	info, err := os.Stat(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if info.ModTime().UnixNano() != generation {
+		return false, nil
+	}
+	if err := os.Remove(s.path(key)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	return err
+}
+
+func (s *LocalStore) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *LocalStore) URL(key string) string {
+	return objectURL(s.publicURL, key)
+}
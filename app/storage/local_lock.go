@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// LOCAL_LOCK_FILE is the file of the directory locked around the conditional writes of a local store.
+const LOCAL_LOCK_FILE = ".lock"
+
+// lock takes an exclusive flock of the directory, which serializes the conditional writes of every process using it.
+func (s *LocalStore) lock() (func(), error) {
+	f, err := os.OpenFile(filepath.Join(s.root, LOCAL_LOCK_FILE), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,12 @@
+package storage
+
+import "sync"
+
+var localLock sync.Mutex
+
+// lock serializes the conditional writes of the process, as Windows has no flock. Several processes sharing a
+// local store on Windows are not coordinated.
+func (s *LocalStore) lock() (func(), error) {
+	localLock.Lock()
+	return localLock.Unlock, nil
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Store is a bucket of Amazon S3 or of an S3-compatible service such as MinIO.
+// The credentials are read from the usual AWS environment variables and files.
+type S3Store struct {
+	client    *s3.S3
+	bucket    string
+	publicURL string
+}
+
+// NewS3Store opens the bucket. endpoint is only needed for S3-compatible services, which are addressed path-style.
+func NewS3Store(bucket string, endpoint string, region string, publicURL string) (*S3Store, error) {
+	cfg := aws.NewConfig()
+	if region != "" {
+		cfg = cfg.WithRegion(region)
+	}
+	if endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("session.NewSession: %w", err)
+	}
+
+	if publicURL == "" {
+		if endpoint != "" {
+			publicURL = objectURL(endpoint, bucket)
+		} else {
+			publicURL = fmt.Sprintf("https://%s.s3.amazonaws.com", bucket)
+		}
+	}
+
+	return &S3Store{client: s3.New(sess), bucket: bucket, publicURL: publicURL}, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if isS3NotFound(err) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Put buffers the object, as the S3 API needs to know its length.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+func (s *S3Store) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if isS3NotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *S3Store) URL(key string) string {
+	return objectURL(s.publicURL, key)
+}
+
+func isS3NotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == s3.ErrCodeNoSuchKey || aerr.Code() == "NotFound"
+	}
+	return false
+}
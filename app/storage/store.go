@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// ObjectStore holds the objects of the pipeline: image layers, rendered images and iframe HTMLs.
+type ObjectStore interface {
+	// Get opens the object, ErrNotFound is returned when it doesn't exist
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put writes the object, replacing any previous version
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// URL returns the public URL of the object
+	URL(key string) string
+}
+
+// Open returns the store at location:
+//   - "file:///var/polymorphs/images" for a local directory
+//   - "s3://bucket?endpoint=http://localhost:9000&region=us-east-1" for an S3-compatible bucket
+//   - "gs://bucket" or a bare bucket name for a Google Cloud Storage bucket
+//   - "ar://{manifest id}/{prefix}?gateway=https://arweave.net" for the files of an Arweave manifest, read-only
+//
+// publicURL is the base of the object URLs, the store's own URL scheme is used when it's empty.
+func Open(ctx context.Context, location string, publicURL string) (ObjectStore, error) {
+	if location == "" {
+		return nil, errors.New("storage: empty location")
+	}
+	if !strings.Contains(location, "://") {
+		return NewGCSStore(ctx, location, publicURL)
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return NewLocalStore(u.Path, publicURL)
+	case "s3":
+		return NewS3Store(u.Host, u.Query().Get("endpoint"), u.Query().Get("region"), publicURL)
+	case "gs":
+		return NewGCSStore(ctx, u.Host, publicURL)
+	case "ar":
+		return NewArweaveStore(ctx, u.Query().Get("gateway"), u.Host, u.Path, publicURL)
+	default:
+		return nil, fmt.Errorf("storage: unsupported scheme %q in %q", u.Scheme, location)
+	}
+}
+
+// objectURL joins the public base URL and the key.
+func objectURL(base string, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(key, "/")
+}
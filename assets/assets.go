@@ -0,0 +1,53 @@
+package assets
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+const CONFIG_FILE = "config.json"
+const BADGES_FILE = "badges-config.json"
+const IFRAME_TEMPLATE_FILE = "index.html"
+
+// ASSETS_DIR_ENV names the env variable of the directory whose files replace the embedded ones.
+const ASSETS_DIR_ENV = "ASSETS_DIR"
+
+//go:embed config.json badges-config.json index.html
+var embedded embed.FS
+
+var overrideDir string
+
+// SetOverrideDir makes the files of dir take precedence over the embedded ones. It wins over ASSETS_DIR.
+func SetOverrideDir(dir string) {
+	overrideDir = dir
+}
+
+// Files returns the embedded assets, overridden file by file by the override directory if any.
+func Files() fs.FS {
+	dir := overrideDir
+	if dir == "" {
+		dir = os.Getenv(ASSETS_DIR_ENV)
+	}
+	if dir == "" {
+		return embedded
+	}
+	return overlayFS{override: os.DirFS(dir), base: embedded}
+}
+
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.override.Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
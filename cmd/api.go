@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/polymorph-metadata/app/interface/api"
+	"github.com/polymorph-metadata/app/interface/api/handlers"
+	"github.com/polymorph-metadata/app/interface/api/routers"
+	"github.com/polymorph-metadata/assets"
+)
+
+// runAPI serves the chi API instead of the Cloud Function handlers:
+//
+//	cmd api -env .env
+//
+// GET /token/{tokenId} and POST /txreceipt, along with the routes of the Cloud Function handlers, see routers.Routes.
+func runAPI(args []string) {
+	flags := flag.NewFlagSet("api", flag.ExitOnError)
+	envFile := flags.String("env", "", "env file, .env by default")
+	assetsDir := flags.String("assets", "", "directory whose config.json, badges-config.json and index.html replace the embedded ones")
+	flags.Parse(args)
+
+	if *envFile != "" {
+		godotenv.Load(*envFile)
+	} else {
+		godotenv.Load()
+	}
+
+	if *assetsDir != "" {
+		assets.SetOverrideDir(*assetsDir)
+	}
+
+	ethClient, polygonClient := connectToNodes()
+
+	configHolder := newConfigHolder()
+	watchConfig(configHolder)
+	generator := newGenerator(configHolder, true)
+
+	a := api.NewAPI()
+	a.AddRouter("/token", routers.NewMetadataRouter(ethClient, polygonClient, os.Getenv("CONTRACT_ADDRESS"), os.Getenv("CONTRACT_ADDRESS_POLYGON"), configHolder, handlers.NewBadgeStatsFromEnv(), generator))
+	a.AddRouter("/txreceipt", routers.NewTxReceiptRouter(ethClient))
+	for _, route := range routers.Routes(configHolder, generator, imagePersist()) {
+		a.AddRoute(route.Path, route.Handler, route.Cacheable)
+	}
+
+	if err := a.Start(os.Getenv("API_PORT")); err != nil {
+		log.Fatalf("api.Start: %v\n", err)
+	}
+}
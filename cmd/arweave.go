@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/polymorph-metadata/app/arweave"
+	log "github.com/sirupsen/logrus"
+)
+
+// runArweaveGateway serves an in-memory Arweave bundler and gateway, to publish to Arweave locally:
+//
+//	cmd arweave-gateway -new-wallet wallet.json -layers ./layers
+//	PUBLISHER=arweave ARWEAVE_WALLET=wallet.json ARWEAVE_BUNDLER_URL=http://localhost:1984 ARWEAVE_GATEWAY_URL=http://localhost:1984 cmd api
+func runArweaveGateway(args []string) {
+	flags := flag.NewFlagSet("arweave-gateway", flag.ExitOnError)
+	addr := flags.String("addr", ":1984", "listen address")
+	newWallet := flags.String("new-wallet", "", "write a new wallet file to sign the uploads with")
+	layers := flags.String("layers", "", "directory of layers, e.g. with images/{layer}/{gene}.png, served with a manifest of their paths")
+	flags.Parse(args)
+
+	if *newWallet != "" {
+		key, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		data, err := arweave.MarshalWallet(key)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := os.WriteFile(*newWallet, data, 0600); err != nil {
+			log.Fatalln(err)
+		}
+		log.Infof("Wallet %s written, address %s", *newWallet, arweave.Address(&key.PublicKey))
+	}
+
+	gateway := arweave.NewMockGateway()
+
+	if *layers != "" {
+		id, count, err := putLayers(gateway, *layers)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Infof("%d layer(s) served under the manifest %s, set it as ar://%s", count, id, id)
+	}
+
+	log.Infof("Arweave mock gateway listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, gateway); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// putLayers adds every file of the directory with a flat manifest of their paths, as the Polymorph assets manifest.
+// Ids are derived from the paths, they are stable across runs.
+func putLayers(gateway *arweave.MockGateway, dir string) (string, int, error) {
+	ids := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		id := mockID(key)
+		contentType := "application/octet-stream"
+		if strings.HasSuffix(key, ".png") {
+			contentType = "image/png"
+		}
+		gateway.Put(id, data, contentType)
+		ids[key] = "ar://" + id
+		return nil
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	manifest, err := json.Marshal(ids)
+	if err != nil {
+		return "", 0, err
+	}
+	id := mockID("manifest:" + dir)
+	gateway.Put(id, manifest, "application/json")
+	return id, len(ids), nil
+}
+
+func mockID(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/interface/dlt/ethereum"
+	"github.com/polymorph-metadata/assets"
+	log "github.com/sirupsen/logrus"
+)
+
+// tokenGenome is a line of the input of the badges command.
+type tokenGenome struct {
+	TokenId string `json:"tokenId"`
+	Gene    string `json:"gene"`
+}
+
+// key identifies the line in the report, by its token id or, in a single column input, by its genome.
+func (g tokenGenome) key() string {
+	if g.TokenId != "" {
+		return g.TokenId
+	}
+	return g.Gene
+}
+
+// badgeTokens lists the tokens that get each badge, in the order of the badges config. Inputs without token ids
+// list genomes instead.
+type badgeTokens struct {
+	Name string `json:"name"`
+	// History is set for badges that depend on the genome changes of the token, which are not evaluated offline
+	History bool     `json:"history,omitempty"`
+	Tokens  []string `json:"tokens"`
+}
+
+type badgesReport struct {
+	Evaluated int            `json:"evaluated"`
+	Skipped   []string       `json:"skipped,omitempty"`
+	Badges    []badgeTokens  `json:"badges,omitempty"`
+	Diff      []badgeChanges `json:"diff,omitempty"`
+}
+
+// badgeChanges compares the tokens of a badge under two badges configs.
+type badgeChanges struct {
+	Name   string   `json:"name"`
+	Before int      `json:"before"`
+	After  int      `json:"after"`
+	Gained []string `json:"gained,omitempty"`
+	Lost   []string `json:"lost,omitempty"`
+}
+
+// runBadges evaluates the badges of a list of genomes without the network, e.g. to review a badges-config.json change:
+//
+//	cmd badges -input genomes.csv
+//	cmd badges -env .env -from 1 -to 10000 -badges new-badges.json
+//	cmd badges -input genomes.jsonl -diff new-badges.json
+func runBadges(args []string) {
+	flags := flag.NewFlagSet("badges", flag.ExitOnError)
+	input := flags.String("input", "", "CSV (tokenId,gene or a single gene column) or JSONL ({\"tokenId\":..., \"gene\":...}) file of genomes, - for stdin")
+	from := flags.Int64("from", 0, "first token id to read from the chain when no input is given")
+	to := flags.Int64("to", 0, "last token id to read from the chain")
+	envFile := flags.String("env", "", "env file with the node URLs and contract addresses, for -from and -to")
+	assetsDir := flags.String("assets", "", "directory whose config.json and badges-config.json replace the embedded ones")
+	badgesPath := flags.String("badges", "", "badges config to evaluate instead of the loaded one")
+	diffPath := flags.String("diff", "", "badges config to compare with, prints the tokens that gain or lose each badge")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	if *assetsDir != "" {
+		assets.SetOverrideDir(*assetsDir)
+	}
+
+	configService, err := config.LoadConfigServices(assets.Files(), assets.CONFIG_FILE, assets.BADGES_FILE)
+	if err != nil {
+		log.Fatalf("config.LoadConfigServices: %v\n", err)
+	}
+
+	if *badgesPath != "" {
+		configService = withBadgeConfigFile(configService, *badgesPath)
+	}
+
+	var genomes []tokenGenome
+	switch {
+	case *input != "":
+		genomes, err = readGenomes(*input)
+	case *to > 0:
+		if *envFile != "" {
+			godotenv.Load(*envFile)
+		} else {
+			godotenv.Load()
+		}
+		genomes, err = fetchGenomes(*from, *to)
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var report badgesReport
+	var result []badgeTokens
+	result, report.Evaluated, report.Skipped = evaluateBadges(configService, genomes)
+
+	if *diffPath != "" {
+		other, _, _ := evaluateBadges(withBadgeConfigFile(configService, *diffPath), genomes)
+		report.Diff = diffBadges(result, other)
+	} else {
+		report.Badges = result
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		return
+	}
+	printBadgesReport(os.Stdout, report)
+}
+
+func withBadgeConfigFile(configService *config.ConfigService, path string) *config.ConfigService {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	res, err := configService.WithBadgeConfig(data)
+	if err != nil {
+		log.Fatalf("%s: %v\n", path, err)
+	}
+	return res
+}
+
+// readGenomes reads a JSONL file when its first character is "{", a CSV file otherwise. A CSV header is skipped
+// and a single column CSV lists genomes without token ids.
+func readGenomes(path string) ([]tokenGenome, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		br.UnreadRune()
+		if c == '{' {
+			return readGenomesJSONL(br)
+		}
+		return readGenomesCSV(br)
+	}
+}
+
+func readGenomesJSONL(r io.Reader) ([]tokenGenome, error) {
+	var genomes []tokenGenome
+
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for line := 1; dec.More(); line++ {
+		var raw map[string]interface{}
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if raw["tokenId"] == nil || raw["gene"] == nil {
+			return nil, fmt.Errorf("line %d: tokenId and gene are required", line)
+		}
+		genomes = append(genomes, tokenGenome{TokenId: fmt.Sprint(raw["tokenId"]), Gene: fmt.Sprint(raw["gene"])})
+	}
+
+	return genomes, nil
+}
+
+func readGenomesCSV(r io.Reader) ([]tokenGenome, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var genomes []tokenGenome
+	for i, row := range rows {
+		var tokenId, gene string
+		switch len(row) {
+		case 1:
+			gene = strings.TrimSpace(row[0])
+		default:
+			tokenId, gene = strings.TrimSpace(row[0]), strings.TrimSpace(row[1])
+		}
+		if _, ok := new(big.Int).SetString(gene, 10); !ok && i == 0 {
+			continue
+		}
+		genomes = append(genomes, tokenGenome{TokenId: tokenId, Gene: gene})
+	}
+
+	return genomes, nil
+}
+
+// fetchGenomes reads the genomes of the token range from the chain, skipping tokens that are not minted.
+func fetchGenomes(from int64, to int64) ([]tokenGenome, error) {
+	ethClient, polygonClient := connectToNodes()
+
+	address := os.Getenv("CONTRACT_ADDRESS")
+	addressPolygon := os.Getenv("CONTRACT_ADDRESS_POLYGON")
+	rootTunnelAddress := os.Getenv("ROOT_TUNNEL_ADDRESS")
+
+	var genomes []tokenGenome
+	for id := from; id <= to; id++ {
+		genome, err := ethereumclient.GenomeOf(ethClient, polygonClient, address, addressPolygon, rootTunnelAddress, big.NewInt(id))
+		if errors.Is(err, ethereumclient.ErrTokenNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("token %d: %w", id, err)
+		}
+		genomes = append(genomes, tokenGenome{TokenId: fmt.Sprint(id), Gene: genome.String()})
+	}
+
+	return genomes, nil
+}
+
+// evaluateBadges runs the badge rules over the genomes. Badges that depend on the genome changes of the token
+// are reported without tokens. Genomes that can't be decoded are logged and skipped.
+func evaluateBadges(configService *config.ConfigService, genomes []tokenGenome) ([]badgeTokens, int, []string) {
+	res := make([]badgeTokens, len(configService.Badges.Badges))
+	index := map[string]int{}
+	for i, rule := range configService.Badges.Badges {
+		res[i] = badgeTokens{Name: rule.Name, History: rule.NeedsHistory(), Tokens: []string{}}
+		index[rule.Name] = i
+	}
+
+	evaluated := 0
+	var skipped []string
+
+	for _, g := range genomes {
+		genome := metadata.Genome(g.Gene)
+		decoded, err := genome.Decode(configService.Slots)
+		if err != nil {
+			log.Warnf("%s: %v", g.key(), err)
+			skipped = append(skipped, g.key())
+			continue
+		}
+		evaluated++
+
+		for _, badge := range metadata.EvaluateBadges(&configService.Badges, decoded, nil) {
+			res[index[badge]].Tokens = append(res[index[badge]].Tokens, g.key())
+		}
+	}
+
+	return res, evaluated, skipped
+}
+
+// diffBadges compares the tokens of every badge of either config, in the order of the first one.
+func diffBadges(before []badgeTokens, after []badgeTokens) []badgeChanges {
+	var res []badgeChanges
+
+	find := func(list []badgeTokens, name string) []string {
+		for _, b := range list {
+			if b.Name == name {
+				return b.Tokens
+			}
+		}
+		return nil
+	}
+
+	add := func(name string) {
+		prev, next := find(before, name), find(after, name)
+		changes := badgeChanges{Name: name, Before: len(prev), After: len(next), Gained: missing(next, prev), Lost: missing(prev, next)}
+		if len(changes.Gained) > 0 || len(changes.Lost) > 0 {
+			res = append(res, changes)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, b := range append(append([]badgeTokens{}, before...), after...) {
+		if !seen[b.Name] {
+			seen[b.Name] = true
+			add(b.Name)
+		}
+	}
+
+	return res
+}
+
+// missing returns the tokens of a that are not in b.
+func missing(a []string, b []string) []string {
+	in := map[string]bool{}
+	for _, t := range b {
+		in[t] = true
+	}
+	var res []string
+	for _, t := range a {
+		if !in[t] {
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+func printBadgesReport(w io.Writer, report badgesReport) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "%d genome(s) evaluated, %d skipped\n", report.Evaluated, len(report.Skipped))
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(tw, "skipped:\t%s\n", strings.Join(report.Skipped, ", "))
+	}
+	fmt.Fprintln(tw)
+
+	for _, b := range report.Badges {
+		if b.History {
+			fmt.Fprintf(tw, "%s\t-\tdepends on the scramble history, not evaluated offline\n", b.Name)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", b.Name, len(b.Tokens), strings.Join(b.Tokens, ", "))
+	}
+
+	for _, c := range report.Diff {
+		fmt.Fprintf(tw, "%s\t%d -> %d\t+ %s\t- %s\n", c.Name, c.Before, c.After, strings.Join(c.Gained, ", "), strings.Join(c.Lost, ", "))
+	}
+
+	if report.Diff == nil && report.Badges == nil {
+		fmt.Fprintln(tw, "no badge changes")
+	}
+
+	tw.Flush()
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/storage"
+	"github.com/polymorph-metadata/assets"
+	log "github.com/sirupsen/logrus"
+)
+
+// runBench renders genomes without uploading them and reports the latency and the peak memory of the renders:
+//
+//	cmd bench -synthetic -random 20 -parallel 4
+//	cmd bench -layers gs://polymorph-layers -genomes 1070809121103040506,1000000000000000000 -n 20
+func runBench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	layersLocation := flags.String("layers", "", "layer store, see storage.Open, e.g. a bucket name or file:///path")
+	synthetic := flags.Bool("synthetic", false, "render generated layers from a temporary directory instead of -layers")
+	genomeList := flags.String("genomes", "", "comma separated genomes to render")
+	random := flags.Int("random", 0, "number of random genomes to render")
+	n := flags.Int("n", 0, "number of renders, defaults to one per genome")
+	parallel := flags.Int("parallel", 1, "number of renders at a time, as in a burst of requests")
+	cacheMB := flags.Int("cache-mb", metadata.DEFAULT_LAYER_CACHE_MB, "decoded layer cache in megabytes, 0 disables it")
+	concurrency := flags.Int("concurrency", metadata.DEFAULT_LAYER_CONCURRENCY, "layers fetched and decoded ahead of the composition")
+	transparent := flags.Bool("transparent", false, "leave out the background")
+	assetsDir := flags.String("assets", "", "directory whose config.json replaces the embedded one")
+	flags.Parse(args)
+
+	if *assetsDir != "" {
+		assets.SetOverrideDir(*assetsDir)
+	}
+
+	configService, err := config.LoadConfigServices(assets.Files(), assets.CONFIG_FILE, assets.BADGES_FILE)
+	if err != nil {
+		log.Fatalf("config.LoadConfigServices: %v\n", err)
+	}
+
+	genomes, err := benchGenomes(configService, *genomeList, *random)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if len(genomes) == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *n <= 0 {
+		*n = len(genomes)
+	}
+
+	ctx := context.Background()
+
+	location := *layersLocation
+	if *synthetic {
+		dir, err := ioutil.TempDir("", "polymorph-layers")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer os.RemoveAll(dir)
+		if err := writeSyntheticLayers(dir, configService.Slots, genomes); err != nil {
+			log.Fatalln(err)
+		}
+		location = "file://" + dir
+	}
+	if location == "" {
+		log.Fatalln("-layers or -synthetic is required")
+	}
+
+	layers, err := storage.Open(ctx, location, "")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	configHolder, err := config.NewHolder(func() (*config.ConfigService, error) { return configService, nil })
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	gen := &metadata.Generator{Layers2D: layers, Config: configHolder, LayerConcurrency: *concurrency}
+	if *cacheMB > 0 {
+		gen.LayerCache = metadata.NewLayerCache(*cacheMB)
+	}
+
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	peak := sampleMemory()
+
+	latencies := make([]time.Duration, *n)
+	errs := make([]error, *n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	for w := 0; w < *parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				t := time.Now()
+				errs[i] = renderOnce(ctx, gen, genomes[i%len(genomes)], *transparent)
+				latencies[i] = time.Since(t)
+			}
+		}()
+	}
+	for i := 0; i < *n; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var after runtime.MemStats
+	runtime.ReadMemStats(&after)
+	peakHeap := peak()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			log.Errorf("render %d: %v", i, err)
+		}
+	}
+
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "renders\t%d (%d failed), %d at a time\n", *n, failed, *parallel)
+	fmt.Fprintf(tw, "layer cache\t%d MB, %d layer(s) kept\n", *cacheMB, cachedLayers(gen))
+	fmt.Fprintf(tw, "latency\tmean %v\tp50 %v\tp95 %v\tmax %v\n", (total / time.Duration(*n)).Round(time.Millisecond), percentile(sorted, 50), percentile(sorted, 95), sorted[len(sorted)-1].Round(time.Millisecond))
+	fmt.Fprintf(tw, "throughput\t%.2f renders/s\n", float64(*n)/elapsed.Seconds())
+	fmt.Fprintf(tw, "peak heap\t%d MB\n", peakHeap>>20)
+	fmt.Fprintf(tw, "allocated per render\t%d MB\n", (after.TotalAlloc-before.TotalAlloc)/uint64(*n)>>20)
+	fmt.Fprintf(tw, "memory from the OS\t%d MB\n", after.Sys>>20)
+	tw.Flush()
+}
+
+// renderOnce composes the genome and encodes it as the full size JPEG, without uploading it.
+func renderOnce(ctx context.Context, gen *metadata.Generator, decoded metadata.DecodedGenome, transparent bool) error {
+	img, release, err := gen.ComposeImage(ctx, decoded, false, transparent)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return metadata.ImageEncoders["jpeg"].Encode(ioutil.Discard, img, metadata.DEFAULT_JPEG_QUALITY)
+}
+
+// sampleMemory records the heap in use until the returned function is called, which returns its peak.
+func sampleMemory() func() uint64 {
+	done := make(chan struct{})
+	res := make(chan uint64)
+
+	go func() {
+		var peak uint64
+		var m runtime.MemStats
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&m)
+			if m.HeapInuse > peak {
+				peak = m.HeapInuse
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				res <- peak
+				return
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		return <-res
+	}
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Millisecond)
+}
+
+func cachedLayers(gen *metadata.Generator) int {
+	if gen.LayerCache == nil {
+		return 0
+	}
+	return gen.LayerCache.Len()
+}
+
+// benchGenomes decodes the listed genomes and draws the random ones.
+func benchGenomes(configService *config.ConfigService, list string, random int) ([]metadata.DecodedGenome, error) {
+	var res []metadata.DecodedGenome
+
+	for _, g := range strings.Split(list, ",") {
+		if g = strings.TrimSpace(g); g == "" {
+			continue
+		}
+		genome := metadata.Genome(g)
+		decoded, err := genome.Decode(configService.Slots)
+		if err != nil {
+			return nil, fmt.Errorf("genome %s: %w", g, err)
+		}
+		res = append(res, decoded)
+	}
+
+	for i := 0; i < random; i++ {
+		var traits []string
+		for _, slot := range configService.Slots {
+			traits = append(traits, fmt.Sprintf("%s:%d", slot.Name, rand.Intn(slot.Count)))
+		}
+		decoded, err := metadata.GenomeFromTraits(configService, traits)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, decoded)
+	}
+
+	return res, nil
+}
+
+// writeSyntheticLayers writes a full size layer for every gene of the genomes. Every image layer has its own
+// drawing, shared by its variants so that only one PNG per layer is encoded.
+func writeSyntheticLayers(dir string, layout config.GeneLayout, genomes []metadata.DecodedGenome) error {
+	for _, slot := range layout {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, syntheticLayer(slot.Layer), imaging.PNG); err != nil {
+			return err
+		}
+
+		for _, decoded := range genomes {
+			name := filepath.Join(dir, "images", fmt.Sprint(slot.Layer), fmt.Sprintf("%0*d.png", slot.Width, decoded.Gene(slot.Name)))
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(name, buf.Bytes(), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syntheticLayer draws an opaque background for layer 0 and a translucent band for the others.
+func syntheticLayer(layer int) image.Image {
+	if layer == 0 {
+		return imaging.New(metadata.IMG_SIZE, metadata.IMG_SIZE, color.NRGBA{40, 40, 60, 255})
+	}
+	img := imaging.New(metadata.IMG_SIZE, metadata.IMG_SIZE, color.NRGBA{0, 0, 0, 0})
+	band := imaging.New(metadata.IMG_SIZE, metadata.IMG_SIZE/10, color.NRGBA{uint8(layer * 25), 120, 200, 200})
+	return imaging.Paste(img, band, image.Pt(0, layer*metadata.IMG_SIZE/10))
+}
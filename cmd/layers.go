@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/storage"
+	"github.com/polymorph-metadata/assets"
+	log "github.com/sirupsen/logrus"
+)
+
+// runLayers hashes the layers of both layer stores, bumps the revision of the changed ones in the layer indexes
+// and re-renders the genomes that use them, whose images get a new art version:
+//
+//	cmd layers -env .env -dry-run -input genomes.csv
+//	cmd layers -env .env -render -checkpoint rerender.txt
+func runLayers(args []string) {
+	flags := flag.NewFlagSet("layers", flag.ExitOnError)
+	envFile := flags.String("env", "", "env file, .env by default")
+	assetsDir := flags.String("assets", "", "directory whose config.json, badges-config.json and index.html replace the embedded ones")
+	rerender := flags.Bool("render", false, "re-render the genomes of the input, or of every token of the chain, that use a changed layer")
+	opts := prerenderOptions{staleOnly: true}
+	opts.register(flags)
+	flags.Parse(args)
+
+	if *envFile != "" {
+		godotenv.Load(*envFile)
+	} else {
+		godotenv.Load()
+	}
+
+	if *assetsDir != "" {
+		assets.SetOverrideDir(*assetsDir)
+	}
+
+	configHolder := newConfigHolder()
+	generator := newGenerator(configHolder, false)
+	layout := configHolder.Current().Slots
+
+	ctx := context.Background()
+
+	sources := []struct {
+		name   string
+		v2     bool
+		layers storage.ObjectStore
+	}{
+		{"v1", false, generator.Layers2D},
+	}
+	if os.Getenv("GCLOUD_SOURCE_V2_BUCKET_NAME") != os.Getenv("GCLOUD_SOURCE_V1_BUCKET_NAME") {
+		sources = append(sources, struct {
+			name   string
+			v2     bool
+			layers storage.ObjectStore
+		}{"v2", true, generator.Layers3D})
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	changed := 0
+	opts.changedLayers = map[bool]map[string]bool{false: {}, true: {}}
+
+	for _, s := range sources {
+		previous, err := metadata.ReadLayerIndex(ctx, s.layers)
+		if err != nil {
+			log.Fatalf("%s: %v\n", s.name, err)
+		}
+
+		index, changes, err := metadata.UpdateLayerIndex(ctx, s.layers, layout, previous)
+		if err != nil {
+			log.Fatalf("%s: %v\n", s.name, err)
+		}
+
+		fmt.Fprintf(tw, "%s\t%d layer(s)\t%d changed\n", s.name, len(index.Layers), len(changes))
+		for _, c := range changes {
+			fmt.Fprintf(tw, "\t%s\trevision %d\n", c.Path, c.Revision)
+			opts.changedLayers[s.v2][c.Path] = true
+		}
+		changed += len(changes)
+
+		if !opts.dryRun {
+			if err := metadata.WriteLayerIndex(ctx, s.layers, index); err != nil {
+				log.Fatalf("%s: %v\n", s.name, err)
+			}
+		}
+
+		generator.SetLayerIndex(s.v2, index)
+		if len(sources) == 1 {
+			generator.SetLayerIndex(true, index)
+			opts.changedLayers[true] = opts.changedLayers[false]
+		}
+	}
+	tw.Flush()
+
+	if !*rerender || changed == 0 {
+		return
+	}
+
+	// The genomes using a changed layer have a new art version, so their renditions are missing. The other ones are
+	// skipped, even when they were never rendered
+	if !prerenderCollection(configHolder, generator, opts) {
+		os.Exit(1)
+	}
+}
@@ -1,17 +1,45 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
 	"github.com/joho/godotenv"
 	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
 	"github.com/polymorph-metadata/app/interface/api/handlers"
+	"github.com/polymorph-metadata/app/interface/api/routers"
+	"github.com/polymorph-metadata/assets"
 )
 
+// commands are the subcommands of cmd, which runs the API when none is given.
+var commands = map[string]func(args []string){
+	"badges":          runBadges,
+	"api":             runAPI,
+	"bench":           runBench,
+	"prerender":       runPrerender,
+	"layers":          runLayers,
+	"arweave-gateway": runArweaveGateway,
+}
+
 func main() {
-	args := os.Args[1:]
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			setupLogger()
+			command(os.Args[2:])
+			return
+		}
+	}
+
+	assetsDir := flag.String("assets", "", "directory whose config.json, badges-config.json and index.html replace the embedded ones")
+	flag.Parse()
+
+	args := flag.Args()
 	if len(args) > 0 {
 		godotenv.Load(args[0])
 	} else {
@@ -20,6 +48,10 @@ func main() {
 
 	setupLogger()
 
+	if *assetsDir != "" {
+		assets.SetOverrideDir(*assetsDir)
+	}
+
 	ethClient, polygonClient := connectToNodes()
 
 	port := os.Getenv("API_PORT")
@@ -30,12 +62,59 @@ func main() {
 	contractAddress := os.Getenv("CONTRACT_ADDRESS")
 	contractAddressPolygon := os.Getenv("CONTRACT_ADDRESS_POLYGON")
 
-	configService, badgesJsonMap := config.NewConfigServices("./config.json", "./badges-config.json")
+	configHolder := newConfigHolder()
+	generator := newGenerator(configHolder, true)
+
+	watchConfig(configHolder)
 
-	funcframework.RegisterHTTPFunction("/token", handlers.HandleMetadataRequest(ethClient, polygonClient, contractAddress, contractAddressPolygon, configService, badgesJsonMap))
+	funcframework.RegisterHTTPFunction("/token", handlers.HandleMetadataRequest(ethClient, polygonClient, contractAddress, contractAddressPolygon, configHolder, handlers.NewBadgeStatsFromEnv(), generator))
+	for _, route := range routers.Routes(configHolder, generator, imagePersist()) {
+		funcframework.RegisterHTTPFunction(route.Path, route.Handler)
+	}
 
 	if err := funcframework.Start(port); err != nil {
 		log.Fatalf("funcframework.Start: %v\n", err)
 	}
 
 }
+
+func newConfigHolder() *config.Holder {
+	configHolder, err := config.NewHolder(func() (*config.ConfigService, error) {
+		return config.LoadConfigServices(assets.Files(), assets.CONFIG_FILE, assets.BADGES_FILE)
+	})
+	if err != nil {
+		log.Fatalf("config.LoadConfigServices: %v\n", err)
+	}
+	return configHolder
+}
+
+// watchConfig reloads the config on SIGHUP and every CONFIG_RELOAD_INTERVAL when set.
+func watchConfig(configHolder *config.Holder) {
+	configHolder.ReloadOnSignal()
+	if interval, err := time.ParseDuration(os.Getenv("CONFIG_RELOAD_INTERVAL")); err == nil && interval > 0 {
+		configHolder.Watch(interval)
+	}
+}
+
+// newGenerator maps the buckets of the env, with a render queue when queue is set and RENDER_WORKERS is set.
+func newGenerator(configHolder *config.Holder, queue bool) *metadata.Generator {
+	generator, err := metadata.NewGeneratorFromEnv(context.Background(), handlers.NewExistenceCacheFromEnv())
+	if err != nil {
+		log.Fatalf("metadata.NewGeneratorFromEnv: %v\n", err)
+	}
+	generator.Config = configHolder
+	if err := handlers.UseArweaveAssetsFromEnv(context.Background(), generator); err != nil {
+		log.Fatalln(err)
+	}
+
+	if workers, err := strconv.Atoi(os.Getenv("RENDER_WORKERS")); queue && err == nil && workers > 0 {
+		generator.Queue = metadata.NewRenderQueue(generator, workers, handlers.NewRenderJobStoreFromEnv())
+	}
+	return generator
+}
+
+// imagePersist tells whether the images served by /image are saved to the upload bucket.
+func imagePersist() bool {
+	persist, _ := strconv.ParseBool(os.Getenv("IMAGE_PERSIST"))
+	return persist
+}
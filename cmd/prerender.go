@@ -0,0 +1,444 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"github.com/polymorph-metadata/app/interface/api/handlers"
+	"github.com/polymorph-metadata/app/interface/dlt/ethereum"
+	"github.com/polymorph-metadata/assets"
+	log "github.com/sirupsen/logrus"
+)
+
+// PRERENDER_PROGRESS_INTERVAL is the interval of the progress lines of the prerender command.
+const PRERENDER_PROGRESS_INTERVAL = 10 * time.Second
+
+// prerenderItem is a listed genome, or a token of the chain identified by its index until it's resolved.
+type prerenderItem struct {
+	index   int64
+	tokenId string
+	gene    string
+}
+
+// checkpointKey identifies the item in the checkpoint file.
+func (i prerenderItem) checkpointKey() string {
+	if i.tokenId != "" {
+		return "token:" + i.tokenId
+	}
+	return "genome:" + i.gene
+}
+
+func (i prerenderItem) String() string {
+	if i.tokenId != "" {
+		return "token " + i.tokenId
+	}
+	return "genome " + i.gene
+}
+
+type prerenderStats struct {
+	rendered, upToDate, failed, skipped int64
+}
+
+// runPrerender renders the missing renditions of the whole collection, or of a genome list, and pins the iframes
+// of the tokens, e.g. before a launch:
+//
+//	cmd prerender -env .env -checkpoint prerender.txt
+//	cmd prerender -input genomes.csv -dry-run
+//	cmd prerender -input genomes.txt -force -concurrency 8
+func runPrerender(args []string) {
+	flags := flag.NewFlagSet("prerender", flag.ExitOnError)
+	envFile := flags.String("env", "", "env file, .env by default")
+	assetsDir := flags.String("assets", "", "directory whose config.json, badges-config.json and index.html replace the embedded ones")
+	var opts prerenderOptions
+	opts.register(flags)
+	flags.Parse(args)
+
+	if *envFile != "" {
+		godotenv.Load(*envFile)
+	} else {
+		godotenv.Load()
+	}
+
+	if *assetsDir != "" {
+		assets.SetOverrideDir(*assetsDir)
+	}
+
+	configHolder := newConfigHolder()
+	generator := newGenerator(configHolder, false)
+
+	if !prerenderCollection(configHolder, generator, opts) {
+		os.Exit(1)
+	}
+}
+
+type prerenderOptions struct {
+	input       string
+	concurrency int
+	force       bool
+	iframes     bool
+	checkpoint  string
+	dryRun      bool
+	// publishMetadata publishes the metadata JSON of the tokens and, on Arweave, a path manifest of them
+	publishMetadata bool
+	// staleOnly skips the genomes whose renditions are all rendered, their iframes are not published again
+	staleOnly bool
+	// changedLayers, when set, skips the genomes that draw none of its layer paths, by source: false for the 2D
+	// layers and true for the 3D still layers
+	changedLayers map[bool]map[string]bool
+}
+
+func (o *prerenderOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.input, "input", "", "CSV (tokenId,gene or a single gene column) or JSONL file of genomes, all the tokens of the chain when not set")
+	flags.IntVar(&o.concurrency, "concurrency", 4, "number of genomes rendered at a time")
+	flags.BoolVar(&o.force, "force", false, "render every rendition again")
+	flags.BoolVar(&o.iframes, "iframes", true, "also upload and pin the iframes of the tokens")
+	flags.StringVar(&o.checkpoint, "checkpoint", "", "file of the done items, they are skipped when the command is run again")
+	flags.BoolVar(&o.dryRun, "dry-run", false, "list what would be rendered without rendering")
+	flags.BoolVar(&o.publishMetadata, "publish-metadata", false, "also publish the metadata JSON of the tokens, and on Arweave a manifest of them to use as base URI")
+}
+
+// prerenderCollection renders the missing renditions of the genomes of the input, or of every token of the chain,
+// and tells whether every item succeeded.
+func prerenderCollection(configHolder *config.Holder, generator *metadata.Generator, opts prerenderOptions) bool {
+	var items []prerenderItem
+	var resolve func(prerenderItem) (prerenderItem, error)
+
+	if opts.input != "" {
+		genomes, err := readGenomes(opts.input)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for _, g := range genomes {
+			items = append(items, prerenderItem{index: -1, tokenId: g.TokenId, gene: g.Gene})
+		}
+	} else {
+		ethClient, polygonClient := connectToNodes()
+		address := os.Getenv("CONTRACT_ADDRESS")
+
+		supply, err := ethereumclient.TotalSupply(ethClient, address)
+		if err != nil {
+			log.Fatalf("TotalSupply: %v\n", err)
+		}
+		for i := int64(0); i < supply.Int64(); i++ {
+			items = append(items, prerenderItem{index: i})
+		}
+
+		resolve = func(item prerenderItem) (prerenderItem, error) {
+			tokenId, err := ethereumclient.TokenByIndex(ethClient, address, big.NewInt(item.index))
+			if err != nil {
+				return item, fmt.Errorf("TokenByIndex %d: %w", item.index, err)
+			}
+			item.tokenId = tokenId.String()
+			genome, err := ethereumclient.GenomeOf(ethClient, polygonClient, address, os.Getenv("CONTRACT_ADDRESS_POLYGON"), os.Getenv("ROOT_TUNNEL_ADDRESS"), tokenId)
+			if err != nil {
+				return item, fmt.Errorf("%v: %w", item, err)
+			}
+			item.gene = genome.String()
+			return item, nil
+		}
+	}
+
+	// the badges of every prerendered token are recorded, so that the holders counts cover the whole collection
+	var badgeStats metadata.BadgeStats
+	if !opts.dryRun {
+		badgeStats = handlers.NewBadgeStatsFromEnv()
+	}
+
+	checkpoint, err := openCheckpoint(opts.checkpoint, opts.dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer checkpoint.Close()
+
+	log.Infof("Prerendering %d item(s), %d done in the checkpoint", len(items), checkpoint.Len())
+
+	var stats prerenderStats
+	var done int64
+	var out sync.Mutex
+
+	// tokens lists the tokens of the metadata manifest, done before or now
+	var tokens []string
+	addToken := func(item prerenderItem) {
+		if opts.publishMetadata && item.tokenId != "" {
+			out.Lock()
+			tokens = append(tokens, item.tokenId)
+			out.Unlock()
+		}
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(PRERENDER_PROGRESS_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d := atomic.LoadInt64(&done)
+				log.Infof("%d/%d done, %d rendered, %d up to date, %d failed, %.1f/s", d, len(items), atomic.LoadInt64(&stats.rendered), atomic.LoadInt64(&stats.upToDate), atomic.LoadInt64(&stats.failed), float64(d)/time.Since(start).Seconds())
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	next := make(chan prerenderItem)
+	var wg sync.WaitGroup
+
+	for w := 0; w < opts.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range next {
+				func() {
+					defer atomic.AddInt64(&done, 1)
+
+					if resolve != nil {
+						var err error
+						if item, err = resolve(item); err != nil {
+							atomic.AddInt64(&stats.failed, 1)
+							log.Errorln(err)
+							return
+						}
+					}
+					if checkpoint.Done(item.checkpointKey()) {
+						atomic.AddInt64(&stats.skipped, 1)
+						addToken(item)
+						return
+					}
+
+					configService := configHolder.Current()
+					genome := metadata.Genome(item.gene)
+					decoded, err := genome.Decode(configService.Slots)
+					if err != nil {
+						atomic.AddInt64(&stats.failed, 1)
+						log.Errorf("%v: %v", item, err)
+						return
+					}
+
+					if opts.changedLayers != nil && !generator.UsesLayers(decoded, false, opts.changedLayers[false]) && !generator.UsesLayers(decoded, true, opts.changedLayers[true]) {
+						atomic.AddInt64(&stats.upToDate, 1)
+						return
+					}
+
+					missing, err := generator.MissingImages(ctx, decoded)
+					if err != nil {
+						atomic.AddInt64(&stats.failed, 1)
+						log.Errorf("%v: %v", item, err)
+						return
+					}
+					stale := opts.force || len(missing) > 0
+					if !stale && opts.staleOnly {
+						atomic.AddInt64(&stats.upToDate, 1)
+						return
+					}
+
+					if opts.dryRun {
+						if stale {
+							atomic.AddInt64(&stats.rendered, 1)
+						} else {
+							atomic.AddInt64(&stats.upToDate, 1)
+						}
+						if stale {
+							out.Lock()
+							printPrerenderPlan(item, generator.ImageKey(decoded), missing, opts.force, opts.iframes)
+							out.Unlock()
+						}
+						return
+					}
+
+					if err := prerender(ctx, generator, configService, badgeStats, item, genome, decoded, stale, opts); err != nil {
+						atomic.AddInt64(&stats.failed, 1)
+						log.Errorf("%v: %v", item, err)
+						return
+					}
+					addToken(item)
+					if stale {
+						atomic.AddInt64(&stats.rendered, 1)
+					} else {
+						atomic.AddInt64(&stats.upToDate, 1)
+					}
+					if err := checkpoint.Add(item.checkpointKey()); err != nil {
+						log.Errorf("checkpoint: %v", err)
+					}
+				}()
+			}
+		}()
+	}
+
+	for _, item := range items {
+		if item.index < 0 && checkpoint.Done(item.checkpointKey()) {
+			atomic.AddInt64(&stats.skipped, 1)
+			atomic.AddInt64(&done, 1)
+			addToken(item)
+			continue
+		}
+		next <- item
+	}
+	close(next)
+	wg.Wait()
+	close(stop)
+
+	verb := "rendered"
+	if opts.dryRun {
+		verb = "to render"
+	}
+	log.Infof("%d item(s) in %v: %d %s, %d up to date, %d skipped from the checkpoint, %d failed", len(items), time.Since(start).Round(time.Second), stats.rendered, verb, stats.upToDate, stats.skipped, stats.failed)
+
+	if opts.publishMetadata && !opts.dryRun && generator.Arweave != nil && len(tokens) > 0 {
+		uri, count, err := generator.PublishMetadataManifest(ctx, tokens)
+		if err != nil {
+			log.Errorf("Publishing the metadata manifest: %v", err)
+			return false
+		}
+		log.Infof("Metadata manifest of %d token(s) published, base URI %s/", count, uri)
+	}
+
+	return stats.failed == 0
+}
+
+// prerender renders the images of the item when stale, publishes them when the generator publishes the renditions,
+// and publishes the iframe and the metadata of its token, whose badges are recorded in badgeStats when it's not nil.
+func prerender(ctx context.Context, generator *metadata.Generator, configService *config.ConfigService, badgeStats metadata.BadgeStats, item prerenderItem, genome metadata.Genome, decoded metadata.DecodedGenome, stale bool, opts prerenderOptions) error {
+	if stale {
+		if err := generator.RenderImages(ctx, decoded, opts.force); err != nil {
+			return err
+		}
+	}
+
+	if generator.PublishRenditions {
+		if _, err := generator.PublishImages(ctx, decoded, item.tokenId); err != nil {
+			return err
+		}
+	}
+
+	if item.tokenId == "" {
+		return nil
+	}
+	if !(opts.iframes || opts.publishMetadata) {
+		if badgeStats != nil {
+			return metadata.RecordBadges(badgeStats, item.tokenId, decoded, &configService.Badges)
+		}
+		return nil
+	}
+
+	m, err := genome.Metadata(ctx, item.tokenId, configService, badgeStats, generator)
+	if err != nil {
+		return err
+	}
+	if opts.iframes && m.AnimateUrl == "" {
+		return fmt.Errorf("the iframe was not published")
+	}
+	if opts.publishMetadata {
+		if !generator.LegacyFields {
+			m = m.Standard()
+		}
+		if _, err := generator.PublishMetadata(ctx, item.tokenId, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printPrerenderPlan(item prerenderItem, imageKey string, missing map[string][]string, force bool, iframes bool) {
+	var parts []string
+	switch {
+	case force:
+		parts = append(parts, "every rendition")
+	case len(missing) > 0:
+		for _, source := range []string{"2d", "3d"} {
+			for _, key := range missing[source] {
+				parts = append(parts, source+"/"+key)
+			}
+		}
+	}
+	if iframes && item.tokenId != "" {
+		parts = append(parts, "iframe")
+	}
+	fmt.Printf("%v\t%s\t%s\n", item, imageKey, strings.Join(parts, ", "))
+}
+
+// checkpoint records the done items, one key per line, so that an interrupted run can be resumed.
+type checkpoint struct {
+	mu   sync.Mutex
+	done map[string]bool
+	f    *os.File
+}
+
+// openCheckpoint reads the done items of path and appends the new ones to it, unless readOnly.
+// Without a path, nothing is recorded.
+func openCheckpoint(path string, readOnly bool) (*checkpoint, error) {
+	c := &checkpoint{done: map[string]bool{}}
+	if path == "" {
+		return c, nil
+	}
+
+	f, err := os.Open(path)
+	if err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				c.done[line] = true
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading checkpoint %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if !readOnly {
+		if c.f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *checkpoint) Done(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[key]
+}
+
+func (c *checkpoint) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.done)
+}
+
+func (c *checkpoint) Add(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done[key] {
+		return nil
+	}
+	c.done[key] = true
+	if c.f == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(c.f, key)
+	return err
+}
+
+func (c *checkpoint) Close() error {
+	if c.f == nil {
+		return nil
+	}
+	return c.f.Close()
+}
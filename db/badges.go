@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polymorph-metadata/app/cache"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BADGE_HOLDERS_TTL is how long the holders counts are served from memory before they're counted again. A recorded
+// token is written again after the same time even when its badges didn't change.
+const BADGE_HOLDERS_TTL = 5 * time.Minute
+
+// BADGE_RECORDS_CACHE_SIZE is the number of tokens whose recorded badges are remembered, to skip unchanged writes.
+const BADGE_RECORDS_CACHE_SIZE = 20000
+
+type recordedBadges struct {
+	badges     string
+	recordedAt time.Time
+}
+
+// BadgeStats keeps the current badges of every recorded token in a collection, one document per token.
+type BadgeStats struct {
+	collection *mongo.Collection
+	recorded   *cache.LRU
+
+	mu        sync.Mutex
+	holders   map[string]int64
+	countedAt time.Time
+}
+
+// NewBadgeStats returns the badge stats stored in the given collection.
+func NewBadgeStats(dbName string, collectionName string) (*BadgeStats, error) {
+	collection, err := GetMongoDbCollection(dbName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return &BadgeStats{collection: collection, recorded: cache.NewLRU(BADGE_RECORDS_CACHE_SIZE)}, nil
+}
+
+// Record replaces the badges of the token. The write is skipped when this process recorded the same badges for the
+// token less than BADGE_HOLDERS_TTL ago.
+func (s *BadgeStats) Record(tokenId string, badges []string) error {
+	joined := strings.Join(badges, "\n")
+	if r, ok := s.recorded.Get(tokenId); ok {
+		if r := r.(recordedBadges); r.badges == joined && time.Since(r.recordedAt) < BADGE_HOLDERS_TTL {
+			return nil
+		}
+	}
+
+	_, err := s.collection.UpdateOne(
+		context.Background(),
+		bson.M{"tokenid": tokenId},
+		bson.M{"$set": bson.M{"badges": badges, "updatedat": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	s.recorded.Add(tokenId, recordedBadges{badges: joined, recordedAt: time.Now()})
+	return nil
+}
+
+// Holders returns the number of recorded tokens that hold the badge. The counts of every badge are computed
+// together, by a single aggregation, at most once per BADGE_HOLDERS_TTL.
+func (s *BadgeStats) Holders(badge string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.holders == nil || time.Since(s.countedAt) >= BADGE_HOLDERS_TTL {
+		holders, err := s.countHolders()
+		if err != nil {
+			return 0, err
+		}
+		s.holders, s.countedAt = holders, time.Now()
+	}
+	return s.holders[badge], nil
+}
+
+func (s *BadgeStats) countHolders() (map[string]int64, error) {
+	ctx := context.Background()
+	cursor, err := s.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$badges"}},
+		{{Key: "$group", Value: bson.M{"_id": "$badges", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var counts []struct {
+		Badge string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &counts); err != nil {
+		return nil, err
+	}
+
+	holders := make(map[string]int64, len(counts))
+	for _, c := range counts {
+		holders[c.Badge] = c.Count
+	}
+	return holders, nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RenderedObjects lists the URLs of the rendered images and iframes, shared by every instance of the service.
+type RenderedObjects struct {
+	collection *mongo.Collection
+}
+
+// NewRenderedObjects returns the rendered objects stored in the given collection.
+func NewRenderedObjects(dbName string, collectionName string) (*RenderedObjects, error) {
+	collection, err := GetMongoDbCollection(dbName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderedObjects{collection: collection}, nil
+}
+
+// Has tells whether the object was rendered.
+func (o *RenderedObjects) Has(ctx context.Context, url string) (bool, error) {
+	count, err := o.collection.CountDocuments(ctx, bson.M{"url": url}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// Add records the object as rendered.
+func (o *RenderedObjects) Add(ctx context.Context, url string) error {
+	_, err := o.collection.UpdateOne(
+		ctx,
+		bson.M{"url": url},
+		bson.M{"$setOnInsert": bson.M{"url": url, "renderedat": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/polymorph-metadata/app/domain/metadata"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RenderJobs keeps the render jobs in a collection, one document per render key.
+type RenderJobs struct {
+	collection *mongo.Collection
+}
+
+type renderJobDocument struct {
+	Key       string    `bson:"key"`
+	Genes     []string  `bson:"genes"`
+	Status    string    `bson:"status"`
+	Error     string    `bson:"error,omitempty"`
+	UpdatedAt time.Time `bson:"updatedat"`
+}
+
+// NewRenderJobs returns the render jobs stored in the given collection.
+func NewRenderJobs(dbName string, collectionName string) (*RenderJobs, error) {
+	collection, err := GetMongoDbCollection(dbName, collectionName)
+	if err != nil {
+		return nil, err
+	}
+	return &RenderJobs{collection: collection}, nil
+}
+
+func (j *RenderJobs) Save(job metadata.RenderJob) error {
+	_, err := j.collection.ReplaceOne(
+		context.Background(),
+		bson.M{"key": job.Key},
+		renderJobDocument(job),
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (j *RenderJobs) Get(key string) (*metadata.RenderJob, error) {
+	var doc renderJobDocument
+	err := j.collection.FindOne(context.Background(), bson.M{"key": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job := metadata.RenderJob(doc)
+	return &job, nil
+}
+
+func (j *RenderJobs) Pending() ([]metadata.RenderJob, error) {
+	ctx := context.Background()
+	cursor, err := j.collection.Find(ctx, bson.M{"status": bson.M{"$in": []string{metadata.RENDER_STATUS_QUEUED, metadata.RENDER_STATUS_RENDERING}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []metadata.RenderJob
+	for cursor.Next(ctx) {
+		var doc renderJobDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, metadata.RenderJob(doc))
+	}
+	return jobs, cursor.Err()
+}
+
+// Claim updates the job only when its updatedat is still the one that was read, so that a single instance claims it.
+func (j *RenderJobs) Claim(job metadata.RenderJob, at time.Time) (bool, error) {
+	res, err := j.collection.UpdateOne(
+		context.Background(),
+		bson.M{"key": job.Key, "updatedat": job.UpdatedAt},
+		bson.M{"$set": bson.M{"updatedat": at}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
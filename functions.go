@@ -1,11 +1,18 @@
 package functions
 
 import (
+	"context"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/go-chi/render"
 	"github.com/polymorph-metadata/app/config"
+	"github.com/polymorph-metadata/app/domain/metadata"
 	"github.com/polymorph-metadata/app/interface/api/handlers"
+	"github.com/polymorph-metadata/assets"
+	log "github.com/sirupsen/logrus"
 )
 
 func setCORS(w http.ResponseWriter, r *http.Request) (write http.ResponseWriter, response *http.Request) {
@@ -23,6 +30,55 @@ func setCORS(w http.ResponseWriter, r *http.Request) (write http.ResponseWriter,
 	return w, r
 }
 
+var configHolder *config.Holder
+var configHolderErr error
+var configHolderOnce sync.Once
+
+var badgeStats metadata.BadgeStats
+var badgeStatsOnce sync.Once
+
+var generator *metadata.Generator
+var generatorErr error
+var generatorOnce sync.Once
+
+// getConfigHolder loads the config once per instance, watching it when CONFIG_RELOAD_INTERVAL is set.
+func getConfigHolder() (*config.Holder, error) {
+	configHolderOnce.Do(func() {
+		configHolder, configHolderErr = config.NewHolder(func() (*config.ConfigService, error) {
+			return config.LoadConfigServices(assets.Files(), assets.CONFIG_FILE, assets.BADGES_FILE)
+		})
+		if configHolderErr != nil {
+			return
+		}
+		if interval, err := time.ParseDuration(os.Getenv("CONFIG_RELOAD_INTERVAL")); err == nil && interval > 0 {
+			configHolder.Watch(interval)
+		}
+	})
+	return configHolder, configHolderErr
+}
+
+func getBadgeStats() metadata.BadgeStats {
+	badgeStatsOnce.Do(func() {
+		badgeStats = handlers.NewBadgeStatsFromEnv()
+	})
+	return badgeStats
+}
+
+// getGenerator opens the image and iframe stores once per instance.
+func getGenerator() (*metadata.Generator, error) {
+	generatorOnce.Do(func() {
+		generator, generatorErr = metadata.NewGeneratorFromEnv(context.Background(), handlers.NewExistenceCacheFromEnv())
+		if generatorErr != nil {
+			return
+		}
+		if generatorErr = handlers.UseArweaveAssetsFromEnv(context.Background(), generator); generatorErr != nil {
+			return
+		}
+		generator.Config, generatorErr = getConfigHolder()
+	})
+	return generator, generatorErr
+}
+
 func TokenIframeMetadata(w http.ResponseWriter, r *http.Request) {
 
 	w, r = setCORS(w, r)
@@ -30,7 +86,21 @@ func TokenIframeMetadata(w http.ResponseWriter, r *http.Request) {
 	contractAddress := os.Getenv("CONTRACT_ADDRESS")
 	contractAddressPolygon := os.Getenv("CONTRACT_ADDRESS_POLYGON")
 
-	configService, badgesJsonMap := config.NewConfigServices("./serverless_function_source_code/config.json", "./serverless_function_source_code/badges-config.json")
+	configHolder, err := getConfigHolder()
+	if err != nil {
+		render.Status(r, 500)
+		render.JSON(w, r, err.Error())
+		log.Errorln(err)
+		return
+	}
+
+	generator, err := getGenerator()
+	if err != nil {
+		render.Status(r, 500)
+		render.JSON(w, r, err.Error())
+		log.Errorln(err)
+		return
+	}
 
-	handlers.HandleMetadataRequest(ethClient, polygonClient, contractAddress, contractAddressPolygon, configService, badgesJsonMap)(w, r)
+	handlers.HandleMetadataRequest(ethClient, polygonClient, contractAddress, contractAddressPolygon, configHolder, getBadgeStats(), generator)(w, r)
 }
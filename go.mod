@@ -5,6 +5,7 @@ go 1.16
 require (
 	cloud.google.com/go/storage v1.15.0
 	github.com/GoogleCloudPlatform/functions-framework-go v1.2.0
+	github.com/aws/aws-sdk-go v1.34.28
 	github.com/disintegration/imaging v1.6.2
 	github.com/ethereum/go-ethereum v1.10.18
 	github.com/go-chi/chi v1.5.1
@@ -14,4 +15,7 @@ require (
 	github.com/machinebox/graphql v0.2.2 // indirect
 	github.com/sirupsen/logrus v1.7.0
 	go.mongodb.org/mongo-driver v1.4.4
+	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
+	google.golang.org/api v0.45.0
+	google.golang.org/api v0.45.0
 )